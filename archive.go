@@ -0,0 +1,116 @@
+package goddd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+var ErrNotArchived = errors.New("stream is not archived")
+
+// ArchiveStore keeps the events of cold streams out of the event store.
+// Keys identify a stream and its event store, they are chosen by the repository.
+type ArchiveStore interface {
+	// Put stores the whole stream, replacing any previous archive of it
+	Put(ctx context.Context, key string, events []Event) error
+	// Get returns the archived stream, ErrNotArchived if there is none
+	Get(ctx context.Context, key string) ([]Event, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// encodeArchive compresses the events encoded in JSON, which unlike protobuf keeps the timestamps precision
+func encodeArchive(events []Event) ([]byte, error) {
+	encoded := make([]jsonEvent, len(events))
+	for i, event := range events {
+		encoded[i] = jsonEvent{
+			ID:        event.Id(),
+			ObjectID:  event.ObjectId(),
+			Name:      event.Name(),
+			Version:   event.Version(),
+			Timestamp: event.Timestamp(),
+			Payload:   event.Payload(),
+		}
+	}
+
+	buffer := bytes.Buffer{}
+	writer := gzip.NewWriter(&buffer)
+	if err := json.NewEncoder(writer).Encode(encoded); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func decodeArchive(archive []byte) ([]Event, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	decoded := make([]jsonEvent, 0)
+	if err := json.NewDecoder(reader).Decode(&decoded); err != nil {
+		return nil, err
+	}
+	events := make([]Event, len(decoded))
+	for i, event := range decoded {
+		events[i] = ReloadEvent(event.ID, event.ObjectID, event.Name, event.Version, event.Payload, event.Timestamp)
+	}
+	return events, nil
+}
+
+// FileArchiveStore is an ArchiveStore writing each stream to a compressed file of a local directory
+type FileArchiveStore struct {
+	dir string
+}
+
+func NewFileArchiveStore(dir string) (*FileArchiveStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileArchiveStore{dir: dir}, nil
+}
+
+func (s *FileArchiveStore) Put(ctx context.Context, key string, events []Event) error {
+	archive, err := encodeArchive(events)
+	if err != nil {
+		return err
+	}
+
+	path := s.path(key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, archive, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *FileArchiveStore) Get(ctx context.Context, key string) ([]Event, error) {
+	archive, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotArchived
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeArchive(archive)
+}
+
+func (s *FileArchiveStore) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileArchiveStore) path(key string) string {
+	return filepath.Join(s.dir, url.QueryEscape(key)+".events.gz")
+}
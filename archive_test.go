@@ -0,0 +1,43 @@
+package goddd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileArchiveStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileArchiveStore(t.TempDir())
+	assert.NoError(t, err)
+	events := []Event{
+		NewEvent("Student/1", "GradeSet", 0, []byte("a")),
+		NewEvent("Student/1", "GradeSet", 1, []byte("b")),
+	}
+
+	_, err = store.Get(ctx, "db.event_store:Student/1")
+	assert.ErrorIs(t, err, ErrNotArchived)
+
+	assert.NoError(t, store.Put(ctx, "db.event_store:Student/1", events))
+	archived, err := store.Get(ctx, "db.event_store:Student/1")
+	assert.NoError(t, err)
+	assert.Len(t, archived, 2)
+	for i, event := range archived {
+		assert.Equal(t, events[i].Id(), event.Id())
+		assert.Equal(t, events[i].Version(), event.Version())
+		assert.Equal(t, events[i].Payload(), event.Payload())
+		assert.Equal(t, events[i].Timestamp(), event.Timestamp())
+	}
+
+	assert.NoError(t, store.Delete(ctx, "db.event_store:Student/1"))
+	assert.NoError(t, store.Delete(ctx, "db.event_store:Student/1"))
+	_, err = store.Get(ctx, "db.event_store:Student/1")
+	assert.ErrorIs(t, err, ErrNotArchived)
+}
+
+func TestAddArchivedEventNameReserved(t *testing.T) {
+	object := Student{}
+	err := object.Stream.AddEvent(&object, "Archived_Stream", GradeSet{"a"})
+	assert.Error(t, err)
+}
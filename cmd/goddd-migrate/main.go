@@ -0,0 +1,112 @@
+// Command goddd-migrate applies the pending migrations of a Mongo event store.
+//
+// Usage:
+//
+//	goddd-migrate -uri mongodb://localhost:27017 -database app [-dir ./migrations] [-prefix students_]
+//		[-events event_store] [-snapshots domain_event_snapshots]
+//		[-tenant acme [-tenancy collection|database] [-tenant-database-prefix app_]] [-status]
+//
+// The collection names and tenant layout are the ones of a MongoRepository created with the same options.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/owlint/goddd"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var tenancies = map[string]goddd.TenantMode{
+	"collection": goddd.TenantCollection,
+	"database":   goddd.TenantDatabase,
+}
+
+func main() {
+	uri := flag.String("uri", "mongodb://localhost:27017", "MongoDB connection URI")
+	databaseName := flag.String("database", "", "database holding the event store")
+	dir := flag.String("dir", "./migrations", "directory of the NNN_description.json migrations")
+	prefix := flag.String("prefix", "", "collection prefix of the event store")
+	events := flag.String("events", "", "events collection name, event_store by default")
+	snapshots := flag.String("snapshots", "", "snapshots collection name, domain_event_snapshots by default")
+	tenant := flag.String("tenant", "", "tenant whose event store is migrated")
+	tenancy := flag.String("tenancy", "collection", "tenant isolation of the event store, collection or database")
+	tenantDatabasePrefix := flag.String("tenant-database-prefix", "", "prefix of the tenant databases, the database name followed by an underscore by default")
+	status := flag.Bool("status", false, "list the pending migrations without applying them")
+	flag.Parse()
+
+	if *databaseName == "" {
+		fmt.Fprintln(os.Stderr, "missing -database")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	repositoryOptions := goddd.MongoRepositoryOptions{
+		EventsCollection:     *events,
+		SnapshotsCollection:  *snapshots,
+		CollectionPrefix:     *prefix,
+		TenantDatabasePrefix: *tenantDatabasePrefix,
+	}
+	if *tenant != "" {
+		mode, ok := tenancies[*tenancy]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "invalid -tenancy %q\n", *tenancy)
+			flag.Usage()
+			os.Exit(2)
+		}
+		repositoryOptions.Tenancy = mode
+	}
+
+	if err := run(*uri, *databaseName, *dir, repositoryOptions, *tenant, *status); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(uri, databaseName, dir string, repositoryOptions goddd.MongoRepositoryOptions, tenant string, status bool) error {
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(ctx)
+	database := client.Database(databaseName)
+
+	fileMigrations, err := goddd.LoadMigrations(dir)
+	if err != nil {
+		return err
+	}
+	eventStoreMigrator, err := goddd.EventStoreMigrator(database, repositoryOptions, tenant)
+	if err != nil {
+		return err
+	}
+	migrators := []*goddd.Migrator{
+		eventStoreMigrator,
+		goddd.NewMigrator(database, "migrations", fileMigrations),
+	}
+
+	for _, migrator := range migrators {
+		if status {
+			pending, err := migrator.Pending(ctx)
+			if err != nil {
+				return err
+			}
+			for _, migration := range pending {
+				fmt.Printf("pending %d %s\n", migration.Version, migration.Description)
+			}
+			continue
+		}
+
+		applied, err := migrator.Migrate(ctx)
+		if err != nil {
+			return err
+		}
+		for _, version := range applied {
+			fmt.Printf("applied %d\n", version)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package goddd
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeadLetter is an event whose delivery failed after all retries
+type DeadLetter struct {
+	ID       string
+	Event    Event
+	Receiver string
+	Error    string
+	Attempts int
+	FailedAt time.Time
+}
+
+// DeadLetterStore keeps failed deliveries so they can be inspected and replayed
+type DeadLetterStore interface {
+	Store(ctx context.Context, letter DeadLetter) error
+	List(ctx context.Context) ([]DeadLetter, error)
+	Remove(ctx context.Context, letterID string) error
+}
+
+// InMemoryDeadLetterStore is a DeadLetterStore keeping letters in memory
+type InMemoryDeadLetterStore struct {
+	mutex   sync.Mutex
+	letters map[string]DeadLetter
+}
+
+func NewDeadLetter(event Event, receiver EventHandler, err error, attempts int) DeadLetter {
+	return DeadLetter{
+		ID:       uuid.NewString(),
+		Event:    event,
+		Receiver: handlerName(receiver),
+		Error:    err.Error(),
+		Attempts: attempts,
+		FailedAt: time.Now(),
+	}
+}
+
+// handlerName identifies a handler by its name when it is a NamedHandler, by the name of its function when it
+// is a ReceiverFunc and by its type otherwise
+func handlerName(handler EventHandler) string {
+	switch h := handler.(type) {
+	case NamedHandler:
+		return h.HandlerName()
+	case ReceiverFunc:
+		return funcName(h)
+	case receiverHandler:
+		return fmt.Sprintf("%T", h.receiver)
+	case batchReceiverHandler:
+		return fmt.Sprintf("%T", h.receiver)
+	}
+	return fmt.Sprintf("%T", handler)
+}
+
+// funcName returns the qualified name of a function, suffixed with .funcN for closures
+func funcName(function interface{}) string {
+	if f := runtime.FuncForPC(reflect.ValueOf(function).Pointer()); f != nil {
+		return f.Name()
+	}
+	return fmt.Sprintf("%T", function)
+}
+
+func NewInMemoryDeadLetterStore() *InMemoryDeadLetterStore {
+	return &InMemoryDeadLetterStore{
+		letters: make(map[string]DeadLetter),
+	}
+}
+
+func (s *InMemoryDeadLetterStore) Store(ctx context.Context, letter DeadLetter) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.letters[letter.ID] = letter
+	return nil
+}
+
+// List returns the stored letters, oldest first
+func (s *InMemoryDeadLetterStore) List(ctx context.Context) ([]DeadLetter, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	letters := make([]DeadLetter, 0, len(s.letters))
+	for _, letter := range s.letters {
+		letters = append(letters, letter)
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+
+	return letters, nil
+}
+
+func (s *InMemoryDeadLetterStore) Remove(ctx context.Context, letterID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.letters, letterID)
+	return nil
+}
+
+// ReplayDeadLetters hands every stored letter to handler and removes the ones it processed successfully.
+// It returns the number of replayed letters and the first handler error encountered.
+func ReplayDeadLetters(ctx context.Context, store DeadLetterStore, handler EventHandler) (int, error) {
+	letters, err := store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	var firstErr error
+	for _, letter := range letters {
+		if err := handler.HandleEvent(ctx, letter.Event); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if err := store.Remove(ctx, letter.ID); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+
+	return replayed, firstErr
+}
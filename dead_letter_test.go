@@ -0,0 +1,61 @@
+package goddd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayDeadLetters(t *testing.T) {
+	store := NewInMemoryDeadLetterStore()
+	handler := failingHandler{}
+	event1 := NewEvent("TestObject", "name", 1, []byte{1, 2})
+	event2 := NewEvent("TestObject", "name", 2, []byte{1, 2})
+	assert.NoError(t, store.Store(context.Background(), NewDeadLetter(event1, &handler, errors.New("bam"), 1)))
+	assert.NoError(t, store.Store(context.Background(), NewDeadLetter(event2, &handler, errors.New("bam"), 1)))
+
+	replayed, err := ReplayDeadLetters(context.Background(), store, &handler)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, replayed)
+	assert.Equal(t, 2, handler.calls)
+	letters, err := store.List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, letters, 0)
+}
+
+func TestReplayDeadLettersKeepsFailures(t *testing.T) {
+	store := NewInMemoryDeadLetterStore()
+	handler := failingHandler{failures: 1}
+	event := NewEvent("TestObject", "name", 1, []byte{1, 2})
+	assert.NoError(t, store.Store(context.Background(), NewDeadLetter(event, &handler, errors.New("bam"), 1)))
+
+	replayed, err := ReplayDeadLetters(context.Background(), store, &handler)
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, replayed)
+	letters, err := store.List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, "*goddd.failingHandler", letters[0].Receiver)
+}
+
+func failingReceiverFunc(ctx context.Context, event Event) error {
+	return errors.New("bam")
+}
+
+func TestDeadLetterReceiver(t *testing.T) {
+	event := NewEvent("TestObject", "name", 1, []byte{1, 2})
+	closure := ReceiverFunc(func(ctx context.Context, event Event) error { return nil })
+
+	assert.Equal(t, "mailer", NewDeadLetter(event, Named("mailer", closure), errors.New("bam"), 1).Receiver)
+	assert.Equal(t, "github.com/owlint/goddd.failingReceiverFunc", NewDeadLetter(event, ReceiverFunc(failingReceiverFunc), errors.New("bam"), 1).Receiver)
+	assert.Equal(t, "github.com/owlint/goddd.TestDeadLetterReceiver.func1", NewDeadLetter(event, closure, errors.New("bam"), 1).Receiver)
+	assert.Equal(t, "*goddd.testReceiver", NewDeadLetter(event, receiverHandler{receiver: &testReceiver{}}, errors.New("bam"), 1).Receiver)
+	assert.Equal(t, "*goddd.failingHandler", NewDeadLetter(event, &failingHandler{}, errors.New("bam"), 1).Receiver)
+
+	_, batch := Named("recorder", &batchRecorder{}).(BatchEventHandler)
+	assert.True(t, batch)
+}
@@ -0,0 +1,170 @@
+package goddd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeJSON     = "application/json"
+)
+
+// SchemaVersion is the version of the event body schema written by this package
+const SchemaVersion = 1
+
+// Well known envelope headers
+const (
+	HeaderTraceID    = "trace-id"
+	HeaderProducer   = "producer"
+	HeaderProducedAt = "produced-at"
+)
+
+// envelopeMagic starts every enveloped message. A protobuf message cannot start with a zero byte,
+// which tells enveloped messages from the bare serialized events sent by older publishers.
+var envelopeMagic = []byte{0x00, 'G', 'D', 'E'}
+
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+var ErrUnsupportedSchemaVersion = errors.New("unsupported schema version")
+var ErrInvalidEnvelope = errors.New("invalid envelope")
+
+// Envelope wraps a serialized event with the metadata needed to decode and trace it
+type Envelope struct {
+	ContentType   string            `json:"content_type"`
+	SchemaVersion int               `json:"schema_version"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	Body          []byte            `json:"-"`
+}
+
+type envelopeContextKey struct{}
+
+type jsonEvent struct {
+	ID        string `json:"id"`
+	ObjectID  string `json:"object_id"`
+	Name      string `json:"name"`
+	Version   int    `json:"version"`
+	Timestamp int64  `json:"timestamp"`
+	Payload   []byte `json:"payload"`
+}
+
+// NewEnvelope encodes event with the given content type and headers
+func NewEnvelope(event Event, contentType string, headers map[string]string) (Envelope, error) {
+	var body []byte
+	var err error
+	switch contentType {
+	case ContentTypeProtobuf:
+		body, err = event.Serialize()
+	case ContentTypeJSON:
+		body, err = json.Marshal(jsonEvent{
+			ID:        event.Id(),
+			ObjectID:  event.ObjectId(),
+			Name:      event.Name(),
+			Version:   event.Version(),
+			Timestamp: event.Timestamp(),
+			Payload:   event.Payload(),
+		})
+	default:
+		return Envelope{}, fmt.Errorf("%w : %s", ErrUnsupportedContentType, contentType)
+	}
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{
+		ContentType:   contentType,
+		SchemaVersion: SchemaVersion,
+		Headers:       headers,
+		Body:          body,
+	}, nil
+}
+
+// Marshal encodes the envelope as the magic prefix, the length of the JSON metadata, the metadata and the body
+func (e Envelope) Marshal() ([]byte, error) {
+	metadata, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(metadata)))
+
+	message := make([]byte, 0, len(envelopeMagic)+len(length)+len(metadata)+len(e.Body))
+	message = append(message, envelopeMagic...)
+	message = append(message, length...)
+	message = append(message, metadata...)
+	return append(message, e.Body...), nil
+}
+
+// UnmarshalEnvelope decodes a queued message.
+// A bare serialized event is returned as a protobuf envelope without headers.
+func UnmarshalEnvelope(message []byte) (Envelope, error) {
+	if !bytes.HasPrefix(message, envelopeMagic) {
+		return Envelope{
+			ContentType:   ContentTypeProtobuf,
+			SchemaVersion: SchemaVersion,
+			Body:          message,
+		}, nil
+	}
+
+	message = message[len(envelopeMagic):]
+	if len(message) < 4 {
+		return Envelope{}, ErrInvalidEnvelope
+	}
+	length := binary.BigEndian.Uint32(message)
+	message = message[4:]
+	if uint32(len(message)) < length {
+		return Envelope{}, ErrInvalidEnvelope
+	}
+
+	envelope := Envelope{}
+	if err := json.Unmarshal(message[:length], &envelope); err != nil {
+		return Envelope{}, fmt.Errorf("%w : %s", ErrInvalidEnvelope, err.Error())
+	}
+	envelope.Body = message[length:]
+	return envelope, nil
+}
+
+// Event decodes the envelope body according to its content type
+func (e Envelope) Event() (Event, error) {
+	if e.SchemaVersion > SchemaVersion {
+		return Event{}, fmt.Errorf("%w : %d", ErrUnsupportedSchemaVersion, e.SchemaVersion)
+	}
+
+	switch e.ContentType {
+	case ContentTypeProtobuf:
+		return Deserialize(e.Body)
+	case ContentTypeJSON:
+		decoded := jsonEvent{}
+		if err := json.Unmarshal(e.Body, &decoded); err != nil {
+			return Event{}, err
+		}
+		return ReloadEvent(decoded.ID, decoded.ObjectID, decoded.Name, decoded.Version, decoded.Payload, decoded.Timestamp), nil
+	default:
+		return Event{}, fmt.Errorf("%w : %s", ErrUnsupportedContentType, e.ContentType)
+	}
+}
+
+// DecodeMessage decodes a queued message, enveloped or not
+func DecodeMessage(message []byte) (Event, Envelope, error) {
+	envelope, err := UnmarshalEnvelope(message)
+	if err != nil {
+		return Event{}, Envelope{}, err
+	}
+
+	event, err := envelope.Event()
+	return event, envelope, err
+}
+
+// EnvelopeFromContext returns the envelope of the message being handled by a RemoteEventListener
+func EnvelopeFromContext(ctx context.Context) (Envelope, bool) {
+	envelope, ok := ctx.Value(envelopeContextKey{}).(Envelope)
+	return envelope, ok
+}
+
+func withEnvelope(ctx context.Context, envelope Envelope) context.Context {
+	return context.WithValue(ctx, envelopeContextKey{}, envelope)
+}
@@ -0,0 +1,94 @@
+package goddd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/owlint/goddd/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	for _, contentType := range []string{ContentTypeProtobuf, ContentTypeJSON} {
+		event := NewEvent("TestObject", "name", 1, []byte{0x81, 0xff})
+		envelope, err := NewEnvelope(event, contentType, map[string]string{HeaderTraceID: "trace"})
+		assert.NoError(t, err)
+		message, err := envelope.Marshal()
+		assert.NoError(t, err)
+
+		decoded, decodedEnvelope, err := DecodeMessage(message)
+
+		assert.NoError(t, err)
+		assertEventsEqual(t, event, decoded)
+		assert.Equal(t, event.Id(), decoded.Id())
+		assert.Equal(t, contentType, decodedEnvelope.ContentType)
+		assert.Equal(t, SchemaVersion, decodedEnvelope.SchemaVersion)
+		assert.Equal(t, "trace", decodedEnvelope.Headers[HeaderTraceID])
+	}
+}
+
+func TestEnvelopeLegacyMessage(t *testing.T) {
+	event := NewEvent("TestObject", "name", 1, []byte{1, 2})
+	message, err := event.Serialize()
+	assert.NoError(t, err)
+
+	decoded, envelope, err := DecodeMessage(message)
+
+	assert.NoError(t, err)
+	assertEventsEqual(t, event, decoded)
+	assert.Equal(t, ContentTypeProtobuf, envelope.ContentType)
+	assert.Empty(t, envelope.Headers)
+}
+
+func TestEnvelopeUnsupported(t *testing.T) {
+	event := NewEvent("TestObject", "name", 1, []byte{1, 2})
+	_, err := NewEnvelope(event, "text/xml", nil)
+	assert.ErrorIs(t, err, ErrUnsupportedContentType)
+
+	envelope, err := NewEnvelope(event, ContentTypeProtobuf, nil)
+	assert.NoError(t, err)
+	envelope.SchemaVersion = SchemaVersion + 1
+	message, err := envelope.Marshal()
+	assert.NoError(t, err)
+	_, _, err = DecodeMessage(message)
+	assert.ErrorIs(t, err, ErrUnsupportedSchemaVersion)
+
+	_, _, err = DecodeMessage(message[:6])
+	assert.ErrorIs(t, err, ErrInvalidEnvelope)
+}
+
+func TestRemoteListenerEnvelope(t *testing.T) {
+	queue := services.NewInMemoryQueueService(0)
+	errChan := make(chan error, 1)
+	envelopes := make(chan Envelope, 2)
+
+	remotePublisher := NewRemoteEventPublisher(queue, errChan)
+	remotePublisher.Producer = "grades"
+	remotePublisher.Headers = func(event Event) map[string]string {
+		return map[string]string{HeaderTraceID: "trace-" + event.Name()}
+	}
+	legacyPublisher := NewRemoteEventPublisher(queue, errChan)
+	legacyPublisher.Legacy = true
+
+	remoteListener := NewRemoteEventListener(queue, nil, errChan)
+	remoteListener.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		envelope, ok := EnvelopeFromContext(ctx)
+		assert.True(t, ok)
+		envelopes <- envelope
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go remoteListener.Listen(ctx)
+	remotePublisher.OnEvent(NewEvent("TestObject", "enveloped", 1, []byte{1, 2}))
+	legacyPublisher.OnEvent(NewEvent("TestObject", "legacy", 2, []byte{1, 2}))
+
+	enveloped := <-envelopes
+	assert.Equal(t, "grades", enveloped.Headers[HeaderProducer])
+	assert.Equal(t, "trace-enveloped", enveloped.Headers[HeaderTraceID])
+	assert.NotEmpty(t, enveloped.Headers[HeaderProducedAt])
+	legacy := <-envelopes
+	assert.Empty(t, legacy.Headers)
+	assert.Len(t, errChan, 0)
+}
@@ -53,10 +53,11 @@ func (event Event) Serialize() ([]byte, error) {
 	unixTimestamp := time.Unix(0, event.Timestamp()).Unix()
 
 	pbEvent := &protobuf.Event{
+		ID:        event.Id(),
 		Timestamp: unixTimestamp,
 		ObjectID:  event.ObjectId(),
 		Name:      event.Name(),
-		Payload:   string(event.Payload()),
+		Payload:   event.Payload(),
 		Version:   int32(event.Version()),
 	}
 
@@ -88,13 +89,17 @@ func ReloadEvent(eventID, objectID, eventName string, version int, payload []byt
 
 func Deserialize(message []byte) (Event, error) {
 	pbEvent := protobuf.Event{}
-	proto.Unmarshal(message, &pbEvent)
+	err := proto.Unmarshal(message, &pbEvent)
+	if err != nil {
+		return Event{}, err
+	}
 
 	event := Event{
+		id:        pbEvent.GetID(),
 		timestamp: time.Unix(int64(pbEvent.Timestamp), 0).UnixNano(),
 		objectID:  pbEvent.GetObjectID(),
 		name:      pbEvent.GetName(),
-		payload:   []byte(pbEvent.GetPayload()),
+		payload:   pbEvent.GetPayload(),
 		version:   int(pbEvent.GetVersion()),
 	}
 	return event, nil
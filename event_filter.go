@@ -0,0 +1,57 @@
+package goddd
+
+import "strings"
+
+// EventFilter selects the events a receiver is interested in.
+// Any predicate on Event can be used as a filter.
+type EventFilter func(event Event) bool
+
+// ForEventNames keeps the events having one of the given names
+func ForEventNames(names ...string) EventFilter {
+	accepted := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		accepted[name] = struct{}{}
+	}
+
+	return func(event Event) bool {
+		_, ok := accepted[event.Name()]
+		return ok
+	}
+}
+
+// ForObjectIDPrefix keeps the events whose object ID starts with prefix
+func ForObjectIDPrefix(prefix string) EventFilter {
+	return func(event Event) bool {
+		return strings.HasPrefix(event.ObjectId(), prefix)
+	}
+}
+
+// ForObjectType keeps the events of objects identified with NewIdentity(objectType)
+func ForObjectType(objectType string) EventFilter {
+	return func(event Event) bool {
+		return ObjectType(event.ObjectId()) == objectType
+	}
+}
+
+func matchesAll(filters []EventFilter, event Event) bool {
+	for _, filter := range filters {
+		if !filter(event) {
+			return false
+		}
+	}
+	return true
+}
+
+func filterEvents(filters []EventFilter, events []Event) []Event {
+	if len(filters) == 0 {
+		return events
+	}
+
+	matching := make([]Event, 0, len(events))
+	for _, event := range events {
+		if matchesAll(filters, event) {
+			matching = append(matching, event)
+		}
+	}
+	return matching
+}
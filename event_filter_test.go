@@ -0,0 +1,36 @@
+package goddd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForEventNames(t *testing.T) {
+	filter := ForEventNames("GradeSet", "NameSet")
+
+	assert.True(t, filter(NewEvent("TestObject", "GradeSet", 1, nil)))
+	assert.True(t, filter(NewEvent("TestObject", "NameSet", 1, nil)))
+	assert.False(t, filter(NewEvent("TestObject", "Other", 1, nil)))
+}
+
+func TestForObjectType(t *testing.T) {
+	filter := ForObjectType("Student")
+
+	assert.True(t, filter(NewEvent(NewIdentity("Student"), "GradeSet", 1, nil)))
+	assert.False(t, filter(NewEvent(NewIdentity("StudentGroup"), "GradeSet", 1, nil)))
+	assert.False(t, filter(NewEvent(NewIdentity("Teacher"), "GradeSet", 1, nil)))
+	assert.False(t, filter(NewEvent(NewIdentity("Student-Class"), "GradeSet", 1, nil)))
+	assert.False(t, filter(NewEvent("Student-1", "GradeSet", 1, nil)))
+
+	hyphenated := ForObjectType("Student-Class")
+	assert.True(t, hyphenated(NewEvent(NewIdentity("Student-Class"), "GradeSet", 1, nil)))
+	assert.False(t, hyphenated(NewEvent(NewIdentity("Student"), "GradeSet", 1, nil)))
+}
+
+func TestForObjectIDPrefix(t *testing.T) {
+	filter := ForObjectIDPrefix("Stu")
+
+	assert.True(t, filter(NewEvent("Student-1", "GradeSet", 1, nil)))
+	assert.False(t, filter(NewEvent("Teacher-1", "GradeSet", 1, nil)))
+}
@@ -0,0 +1,62 @@
+package goddd
+
+import "context"
+
+// EventIterator streams events one at a time:
+//
+//	defer it.Close(ctx)
+//	for it.Next(ctx) {
+//		event := it.Event()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type EventIterator interface {
+	// Next moves to the next event. It returns false once the events are exhausted, on error or when ctx is done.
+	Next(ctx context.Context) bool
+	// Event returns the current event
+	Event() Event
+	// Err returns the error that stopped the iteration, if any
+	Err() error
+	Close(ctx context.Context) error
+}
+
+type sliceEventIterator struct {
+	events  []Event
+	current int
+	err     error
+}
+
+func newSliceEventIterator(events []Event) *sliceEventIterator {
+	return &sliceEventIterator{
+		events:  events,
+		current: -1,
+	}
+}
+
+func (i *sliceEventIterator) Next(ctx context.Context) bool {
+	if i.err != nil {
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		i.err = err
+		return false
+	}
+	if i.current+1 >= len(i.events) {
+		return false
+	}
+	i.current++
+	return true
+}
+
+func (i *sliceEventIterator) Event() Event {
+	return i.events[i.current]
+}
+
+func (i *sliceEventIterator) Err() error {
+	return i.err
+}
+
+func (i *sliceEventIterator) Close(ctx context.Context) error {
+	i.events = nil
+	return nil
+}
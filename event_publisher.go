@@ -2,7 +2,11 @@ package goddd
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"sync"
+	"time"
 
 	"github.com/owlint/goddd/services"
 )
@@ -11,63 +15,612 @@ type EventReceiver interface {
 	OnEvent(event Event)
 }
 
+// EventHandler is an event receiver able to report processing failures
+type EventHandler interface {
+	HandleEvent(ctx context.Context, event Event) error
+}
+
+// ReceiverFunc adapts a function into an EventHandler
+type ReceiverFunc func(ctx context.Context, event Event) error
+
+// HandleEvent calls f(ctx, event)
+func (f ReceiverFunc) HandleEvent(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// BatchEventReceiver is an EventReceiver able to process several events at once
+type BatchEventReceiver interface {
+	EventReceiver
+	OnEvents(events []Event)
+}
+
+// BatchEventHandler is an EventHandler able to process several events at once.
+// When a batch fails or panics, its events are handled again one by one through HandleEvent, including
+// the ones the batch may have already processed: HandleEvents must either apply all the events or none,
+// or HandleEvent must be idempotent.
+type BatchEventHandler interface {
+	EventHandler
+	HandleEvents(ctx context.Context, events []Event) error
+}
+
+// NamedHandler is an EventHandler identified by a name, such as the handlers created with Named
+type NamedHandler interface {
+	EventHandler
+	HandlerName() string
+}
+
+type namedHandler struct {
+	EventHandler
+	name string
+}
+
+func (h namedHandler) HandlerName() string {
+	return h.name
+}
+
+type namedBatchHandler struct {
+	BatchEventHandler
+	name string
+}
+
+func (h namedBatchHandler) HandlerName() string {
+	return h.name
+}
+
+// Named names handler, the name is recorded in the dead letters of the events it failed to handle
+func Named(name string, handler EventHandler) NamedHandler {
+	if batchHandler, ok := handler.(BatchEventHandler); ok {
+		return namedBatchHandler{BatchEventHandler: batchHandler, name: name}
+	}
+	return namedHandler{EventHandler: handler, name: name}
+}
+
+type receiverHandler struct {
+	receiver EventReceiver
+}
+
+func (h receiverHandler) HandleEvent(ctx context.Context, event Event) error {
+	h.receiver.OnEvent(event)
+	return nil
+}
+
+type batchReceiverHandler struct {
+	receiver BatchEventReceiver
+}
+
+func (h batchReceiverHandler) HandleEvent(ctx context.Context, event Event) error {
+	h.receiver.OnEvent(event)
+	return nil
+}
+
+func (h batchReceiverHandler) HandleEvents(ctx context.Context, events []Event) error {
+	h.receiver.OnEvents(events)
+	return nil
+}
+
+// OverflowPolicy tells the publisher what to do when a receiver buffer is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes Publish wait for room in the buffer
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest pending delivery to make room
+	OverflowDropOldest
+	// OverflowError discards the new delivery and reports ErrBufferFull
+	OverflowError
+)
+
+const DefaultBufferSize = 1024
+const DefaultBatchSize = 100
+
+var defaultListenerBackoff = ExponentialBackoff(100*time.Millisecond, 30*time.Second)
+
+var ErrBufferFull = errors.New("receiver buffer is full")
+var ErrPublisherClosed = errors.New("publisher is closed")
+
+// publisherWorkerKey holds the subscription whose handler is given the context
+type publisherWorkerKey struct{}
+
+type delivery struct {
+	event Event
+	done  *sync.WaitGroup
+}
+
+func (d delivery) finish() {
+	if d.done != nil {
+		d.done.Done()
+	}
+}
+
+type subscription struct {
+	handler    EventHandler
+	filters    []EventFilter
+	deliveries chan delivery
+	stop       chan struct{}
+	discard    bool
+	supervisor supervisor
+
+	// spill keeps the deliveries published by the handler itself that did not fit in the buffer
+	spillMutex sync.Mutex
+	spill      []delivery
+}
+
+// Subscription is a receiver registration that can be cancelled
+type Subscription struct {
+	once        sync.Once
+	unsubscribe func()
+}
+
+// Unsubscribe stops the deliveries to the receiver. Pending deliveries are discarded.
+// It is safe to call it several times.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(s.unsubscribe)
+}
+
 type EventPublisher struct {
-	receivers []EventReceiver
-	Wait      bool
+	subscriptions []*subscription
+	Wait          bool
+
+	// BufferSize bounds the pending deliveries of each receiver registered afterwards
+	BufferSize int
+	// Overflow is applied when publishing to a receiver whose buffer is full
+	Overflow OverflowPolicy
+	// BatchSize bounds the pending events handed at once to a BatchEventHandler
+	BatchSize int
+	// Retry is applied to handlers returning an error
+	Retry RetryPolicy
+	// DeadLetters receives the events still failing once retries are exhausted
+	DeadLetters DeadLetterStore
+	// OnError is called with failures that could not be dead lettered
+	OnError func(event Event, err error)
+	// OnPanic is called with every panic recovered from a receiver
+	OnPanic func(event Event, err *PanicError)
+	// QuarantineAfter is the number of consecutive panicking deliveries after which
+	// a receiver stops getting events. Zero disables quarantine.
+	QuarantineAfter int
+	// QuarantineDuration is how long a receiver stays quarantined, forever if zero
+	QuarantineDuration time.Duration
+
+	mutex   sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+	ctx     context.Context
+	cancel  context.CancelFunc
 }
 
 type RemoteEventPublisher struct {
 	queue   services.QueueService
 	errChan chan<- error
+
+	// ContentType is the encoding of the published events, ContentTypeProtobuf by default
+	ContentType string
+	// Producer is sent in the HeaderProducer header when set
+	Producer string
+	// Headers returns additional envelope headers for an event, such as HeaderTraceID
+	Headers func(event Event) map[string]string
+	// Legacy publishes bare serialized events without envelope, for listeners not supporting it yet
+	Legacy bool
 }
 
 type RemoteEventListener struct {
-	queue    services.QueueService
-	receiver EventReceiver
-	errChan  chan<- error
+	queue         services.QueueService
+	errChan       chan<- error
+	mutex         sync.RWMutex
+	subscriptions []*listenerSubscription
+
+	// Workers is the number of events handled concurrently.
+	// Events of the same object are always handled by the same worker, in order.
+	Workers int
+	// Backoff is the delay before receiving again after consecutive queue errors, exponential up to 30 seconds if nil
+	Backoff Backoff
+	// BatchSize is the maximum number of messages popped at once from a services.BatchQueueService
+	BatchSize int
+	// Inbox, when set, skips the already processed events and records the events once every handler succeeded.
+	// Events without ID, such as the ones sent by older publishers, are always handled.
+	Inbox Inbox
+	// MaxDeliveries is the number of failed deliveries of an AckQueueService message after which it is
+	// acknowledged and its event stored in DeadLetters instead of being given back to the queue.
+	// Zero means unlimited, leaving the redelivery limit to the queue.
+	MaxDeliveries int
+	// DeadLetters receives the events of the messages exceeding MaxDeliveries
+	DeadLetters DeadLetterStore
+}
+
+type receivedEvent struct {
+	event    Event
+	envelope Envelope
+	message  services.Message
+}
+
+type listenerSubscription struct {
+	handler EventHandler
+	filters []EventFilter
+}
+
+// Register subscribes receiver to the published events matching all the filters
+func (p *EventPublisher) Register(receiver EventReceiver, filters ...EventFilter) *Subscription {
+	if batchReceiver, ok := receiver.(BatchEventReceiver); ok {
+		return p.RegisterHandler(batchReceiverHandler{receiver: batchReceiver}, filters...)
+	}
+	return p.RegisterHandler(receiverHandler{receiver: receiver}, filters...)
+}
+
+// RegisterHandler subscribes handler to the published events matching all the filters
+func (p *EventPublisher) RegisterHandler(handler EventHandler, filters ...EventFilter) *Subscription {
+	_, subscription := p.register(handler, filters)
+	return subscription
+}
+
+// RegisterScoped subscribes handler until ctx is done or the subscription is cancelled
+func (p *EventPublisher) RegisterScoped(ctx context.Context, handler EventHandler, filters ...EventFilter) *Subscription {
+	sub, subscription := p.register(handler, filters)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			subscription.Unsubscribe()
+		case <-sub.stop:
+		}
+	}()
+
+	return subscription
+}
+
+// register starts the worker of a new subscription.
+// Once the publisher is closed, the subscription is created stopped and never gets events.
+func (p *EventPublisher) register(handler EventHandler, filters []EventFilter) (*subscription, *Subscription) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.ctx == nil {
+		p.ctx, p.cancel = context.WithCancel(context.Background())
+	}
+
+	bufferSize := p.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	sub := &subscription{
+		handler:    handler,
+		filters:    filters,
+		deliveries: make(chan delivery, bufferSize),
+		stop:       make(chan struct{}),
+	}
+	if p.closed {
+		close(sub.stop)
+		return sub, &Subscription{unsubscribe: func() {}}
+	}
+	p.subscriptions = append(p.subscriptions, sub)
+
+	p.workers.Add(1)
+	go p.run(sub)
+
+	return sub, &Subscription{
+		unsubscribe: func() { p.unregister(sub) },
+	}
 }
 
-func (p *EventPublisher) Register(receiver EventReceiver) {
-	p.receivers = append(p.receivers, receiver)
+func (p *EventPublisher) unregister(sub *subscription) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	subscriptions := make([]*subscription, 0, len(p.subscriptions))
+	for _, other := range p.subscriptions {
+		if other != sub {
+			subscriptions = append(subscriptions, other)
+		}
+	}
+	p.subscriptions = subscriptions
+
+	if !p.closed {
+		sub.discard = true
+		close(sub.stop)
+	}
 }
 
 func (p *EventPublisher) OnEvent(event Event) {
 	p.Publish([]Event{event})
 }
 
+// Publish hands events to the matching receivers.
+// When Wait is set, it returns once every receiver processed them.
 func (p *EventPublisher) Publish(events []Event) {
-	var wg sync.WaitGroup
+	p.PublishContext(context.Background(), events)
+}
+
+// PublishContext is Publish called with the context of the caller. When ctx is the context given to a handler
+// of the publisher, the events for that handler are never waited for nor blocked on, since its worker is the
+// caller: they are delivered once the handler returns.
+func (p *EventPublisher) PublishContext(ctx context.Context, events []Event) {
+	var wg *sync.WaitGroup
+	if p.Wait {
+		wg = &sync.WaitGroup{}
+	}
+	owner, _ := ctx.Value(publisherWorkerKey{}).(*subscription)
+
+	p.mutex.RLock()
+	closed := p.closed
+	subscriptions := p.subscriptions
+	p.mutex.RUnlock()
+
+	if closed {
+		for _, event := range events {
+			p.reportError(event, ErrPublisherClosed)
+		}
+		return
+	}
+	// The lock is released first since enqueue may block until a handler, which may register or
+	// unsubscribe receivers, is done
+	for _, sub := range subscriptions {
+		for _, event := range filterEvents(sub.filters, events) {
+			if sub == owner {
+				p.enqueueOwn(sub, delivery{event: event})
+				continue
+			}
+			p.enqueue(sub, delivery{event: event, done: wg})
+		}
+	}
+
+	if wg != nil {
+		wg.Wait()
+	}
+}
+
+// Close stops accepting events and waits for the pending deliveries to be processed.
+// If ctx ends first, the handlers context is cancelled and ctx error is returned.
+func (p *EventPublisher) Close(ctx context.Context) error {
+	p.mutex.Lock()
+	if !p.closed {
+		p.closed = true
+		for _, sub := range p.subscriptions {
+			close(sub.stop)
+		}
+	}
+	p.mutex.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		p.mutex.RLock()
+		if p.cancel != nil {
+			p.cancel()
+		}
+		p.mutex.RUnlock()
+		return ctx.Err()
+	}
+}
 
-	for _, receiver := range p.receivers {
+func (p *EventPublisher) enqueue(sub *subscription, d delivery) {
+	if d.done != nil {
+		d.done.Add(1)
+	}
 
-		if p.Wait {
-			wg.Add(1)
+	switch p.Overflow {
+	case OverflowError:
+		select {
+		case sub.deliveries <- d:
+		default:
+			d.finish()
+			p.reportError(d.event, ErrBufferFull)
 		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case sub.deliveries <- d:
+				return
+			default:
+			}
 
-		go func(receiver EventReceiver) {
-			for _, event := range events {
-				receiver.OnEvent(event)
+			select {
+			case dropped := <-sub.deliveries:
+				dropped.finish()
+				p.reportError(dropped.event, ErrBufferFull)
+			default:
+			}
+		}
+	default:
+		select {
+		case sub.deliveries <- d:
+		case <-sub.stop:
+			d.finish()
+			if !sub.discard {
+				p.reportError(d.event, ErrPublisherClosed)
 			}
+		}
+	}
+}
+
+// enqueueOwn enqueues a delivery published by the handler of sub, which runs on the worker of sub.
+// Blocking would deadlock the worker: the deliveries not fitting in the buffer are spilled, in order,
+// and moved to the buffer once the handler returns.
+func (p *EventPublisher) enqueueOwn(sub *subscription, d delivery) {
+	if p.Overflow != OverflowBlock {
+		p.enqueue(sub, d)
+		return
+	}
+
+	sub.spillMutex.Lock()
+	defer sub.spillMutex.Unlock()
+	if len(sub.spill) == 0 {
+		select {
+		case sub.deliveries <- d:
+			return
+		default:
+		}
+	}
+	sub.spill = append(sub.spill, d)
+}
+
+// refill moves the spilled deliveries to the buffer while it has room
+func (sub *subscription) refill() {
+	sub.spillMutex.Lock()
+	defer sub.spillMutex.Unlock()
+	for len(sub.spill) > 0 {
+		select {
+		case sub.deliveries <- sub.spill[0]:
+			sub.spill = sub.spill[1:]
+		default:
+			return
+		}
+	}
+}
 
-			if p.Wait {
-				wg.Done()
+func (p *EventPublisher) run(sub *subscription) {
+	defer p.workers.Done()
+
+	for {
+		select {
+		case d := <-sub.deliveries:
+			p.process(sub, p.collect(sub, d))
+			sub.refill()
+		case <-sub.stop:
+			for {
+				select {
+				case d := <-sub.deliveries:
+					p.process(sub, p.collect(sub, d))
+					sub.refill()
+				default:
+					return
+				}
 			}
-		}(receiver)
+		}
 	}
+}
 
-	if p.Wait {
-		wg.Wait()
+// collect adds the already pending deliveries to first when the handler can process them as a batch
+func (p *EventPublisher) collect(sub *subscription, first delivery) []delivery {
+	deliveries := []delivery{first}
+	if _, ok := sub.handler.(BatchEventHandler); !ok {
+		return deliveries
+	}
+
+	batchSize := p.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	for len(deliveries) < batchSize {
+		select {
+		case d := <-sub.deliveries:
+			deliveries = append(deliveries, d)
+		default:
+			return deliveries
+		}
+	}
+	return deliveries
+}
+
+func (p *EventPublisher) process(sub *subscription, deliveries []delivery) {
+	defer func() {
+		for _, d := range deliveries {
+			d.finish()
+		}
+	}()
+
+	select {
+	case <-sub.stop:
+		if sub.discard {
+			return
+		}
+	default:
+	}
+
+	ctx := context.WithValue(p.ctx, publisherWorkerKey{}, sub)
+	if len(deliveries) > 1 && p.deliverBatch(ctx, sub, deliveries) {
+		return
+	}
+	for _, d := range deliveries {
+		p.deliver(ctx, sub, d.event)
+	}
+}
+
+// deliverBatch hands the deliveries to the handler in one call.
+// It returns false when they must be delivered one by one instead, which replays the whole batch.
+// A panicking batch counts as one panicking delivery, reported to OnPanic with its first event.
+func (p *EventPublisher) deliverBatch(ctx context.Context, sub *subscription, deliveries []delivery) bool {
+	if sub.supervisor.isQuarantined(time.Now()) {
+		return false
+	}
+
+	events := make([]Event, len(deliveries))
+	for i, d := range deliveries {
+		events[i] = d.event
+	}
+	err := safeHandleBatch(ctx, sub.handler.(BatchEventHandler), events)
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		if p.OnPanic != nil {
+			p.OnPanic(events[0], panicErr)
+		}
+		sub.supervisor.record(err, p.QuarantineAfter, p.QuarantineDuration)
+		return false
+	}
+	if err != nil {
+		return false
+	}
+
+	sub.supervisor.record(nil, p.QuarantineAfter, p.QuarantineDuration)
+	return true
+}
+
+func (p *EventPublisher) deliver(ctx context.Context, sub *subscription, event Event) {
+	if sub.supervisor.isQuarantined(time.Now()) {
+		p.fail(ctx, sub.handler, event, ErrReceiverQuarantined, 0)
+		return
+	}
+
+	attempts, err := p.Retry.Run(ctx, func() error {
+		err := safeHandle(ctx, sub.handler, event)
+		var panicErr *PanicError
+		if errors.As(err, &panicErr) && p.OnPanic != nil {
+			p.OnPanic(event, panicErr)
+		}
+		return err
+	})
+	sub.supervisor.record(err, p.QuarantineAfter, p.QuarantineDuration)
+	if err == nil {
+		return
+	}
+
+	p.fail(ctx, sub.handler, event, err, attempts)
+}
+
+func (p *EventPublisher) fail(ctx context.Context, receiver EventHandler, event Event, err error, attempts int) {
+	if p.DeadLetters != nil {
+		letter := NewDeadLetter(event, receiver, err, attempts)
+		storeErr := p.DeadLetters.Store(ctx, letter)
+		if storeErr == nil {
+			return
+		}
+		err = fmt.Errorf("%w (could not dead letter event %s : %s)", err, event.Id(), storeErr.Error())
+	}
+
+	p.reportError(event, err)
+}
+
+func (p *EventPublisher) reportError(event Event, err error) {
+	if p.OnError != nil {
+		p.OnError(event, err)
 	}
 }
 
 func NewEventPublisher() EventPublisher {
 	return EventPublisher{
-		receivers: make([]EventReceiver, 0),
-		Wait:      false,
+		subscriptions: make([]*subscription, 0),
+		Wait:          false,
+		BufferSize:    DefaultBufferSize,
+		Overflow:      OverflowBlock,
+		BatchSize:     DefaultBatchSize,
 	}
 }
 
-func NewRemoteEventPublisher(queue services.QueueService, errChan chan<- error) EventReceiver {
+func NewRemoteEventPublisher(queue services.QueueService, errChan chan<- error) *RemoteEventPublisher {
 	return &RemoteEventPublisher{
 		queue:   queue,
 		errChan: errChan,
@@ -75,41 +628,344 @@ func NewRemoteEventPublisher(queue services.QueueService, errChan chan<- error)
 }
 
 func (r *RemoteEventPublisher) OnEvent(event Event) {
-	serializedEvent, err := event.Serialize()
-	if err != nil {
-		r.errChan <- err
+	r.OnEvents([]Event{event})
+}
+
+// OnEvents pushes the events in a single batch when the queue is a services.BatchQueueService
+func (r *RemoteEventPublisher) OnEvents(events []Event) {
+	serializedEvents := make([][]byte, 0, len(events))
+	for _, event := range events {
+		serializedEvent, err := r.encode(event)
+		if err != nil {
+			r.errChan <- err
+			continue
+		}
+		serializedEvents = append(serializedEvents, serializedEvent)
+	}
+
+	if queue, ok := r.queue.(services.BatchQueueService); ok && len(serializedEvents) > 1 {
+		if err := queue.PushBatch(context.TODO(), serializedEvents); err != nil {
+			r.errChan <- err
+		}
 		return
 	}
 
-	err = r.queue.Push(context.TODO(), serializedEvent)
+	for _, serializedEvent := range serializedEvents {
+		if err := r.queue.Push(context.TODO(), serializedEvent); err != nil {
+			r.errChan <- err
+		}
+	}
+}
+
+func (r *RemoteEventPublisher) encode(event Event) ([]byte, error) {
+	if r.Legacy {
+		return event.Serialize()
+	}
+
+	headers := map[string]string{
+		HeaderProducedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if r.Producer != "" {
+		headers[HeaderProducer] = r.Producer
+	}
+	if r.Headers != nil {
+		for key, value := range r.Headers(event) {
+			headers[key] = value
+		}
+	}
+
+	contentType := r.ContentType
+	if contentType == "" {
+		contentType = ContentTypeProtobuf
+	}
+	envelope, err := NewEnvelope(event, contentType, headers)
 	if err != nil {
-		r.errChan <- err
-		return
+		return nil, err
 	}
+	return envelope.Marshal()
 }
 
-func NewRemoteEventListener(queue services.QueueService, receiver EventReceiver, errChan chan<- error) RemoteEventListener {
+// NewRemoteEventListener creates a listener handing the queued events matching all the filters to receiver.
+// More handlers can be added with RegisterHandler, receiver may be nil.
+func NewRemoteEventListener(queue services.QueueService, receiver EventReceiver, errChan chan<- error, filters ...EventFilter) RemoteEventListener {
+	subscriptions := make([]*listenerSubscription, 0)
+	if receiver != nil {
+		subscriptions = append(subscriptions, &listenerSubscription{
+			handler: receiverHandler{receiver: receiver},
+			filters: filters,
+		})
+	}
+
 	return RemoteEventListener{
-		queue:    queue,
-		receiver: receiver,
-		errChan:  errChan,
+		queue:         queue,
+		errChan:       errChan,
+		subscriptions: subscriptions,
+		Workers:       1,
+		Backoff:       defaultListenerBackoff,
+		BatchSize:     DefaultBatchSize,
 	}
 }
 
-func (r *RemoteEventListener) Listen() {
-	for {
-		serialized, err := r.queue.Pop(context.TODO())
+// RegisterHandler subscribes handler to the received events matching all the filters
+func (r *RemoteEventListener) RegisterHandler(handler EventHandler, filters ...EventFilter) *Subscription {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	sub := &listenerSubscription{
+		handler: handler,
+		filters: filters,
+	}
+	r.subscriptions = append(r.subscriptions, sub)
+
+	return &Subscription{
+		unsubscribe: func() { r.unregister(sub) },
+	}
+}
+
+func (r *RemoteEventListener) unregister(sub *listenerSubscription) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	subscriptions := make([]*listenerSubscription, 0, len(r.subscriptions))
+	for _, other := range r.subscriptions {
+		if other != sub {
+			subscriptions = append(subscriptions, other)
+		}
+	}
+	r.subscriptions = subscriptions
+}
+
+// Listen hands the queued events to the handlers until ctx is done, then returns ctx error.
+// Both enveloped messages and bare serialized events are accepted, handlers get the envelope through EnvelopeFromContext.
+// With an AckQueueService, messages are acknowledged once every handler succeeded and
+// given back to the queue otherwise. The messages received before ctx is done are still handled,
+// with a context that is not cancelled.
+func (r *RemoteEventListener) Listen(ctx context.Context) error {
+	workers := r.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	backoff := r.Backoff
+	if backoff == nil {
+		backoff = defaultListenerBackoff
+	}
+
+	var wg sync.WaitGroup
+	partitions := make([]chan receivedEvent, workers)
+	for i := range partitions {
+		partitions[i] = make(chan receivedEvent)
+		wg.Add(1)
+		go func(received <-chan receivedEvent) {
+			defer wg.Done()
+			for item := range received {
+				handlerCtx := withEnvelope(detach(ctx), item.envelope)
+				handler, err := r.handle(ctx, handlerCtx, item.event)
+				r.settle(ctx, item.message, &item.event, handler, err)
+			}
+		}(partitions[i])
+	}
+	defer func() {
+		for _, partition := range partitions {
+			close(partition)
+		}
+		wg.Wait()
+	}()
+
+	failures := 0
+	for ctx.Err() == nil {
+		messages, err := r.receive(ctx)
 		if err != nil {
-			r.errChan <- err
+			if ctx.Err() != nil {
+				break
+			}
+			failures++
+			r.report(ctx, err)
+			if sleep(ctx, backoff(failures)) != nil {
+				break
+			}
 			continue
 		}
+		failures = 0
+
+		for _, message := range messages {
+			event, envelope, err := DecodeMessage(message.Body)
+			if err != nil {
+				r.report(ctx, err)
+				r.settle(ctx, message, nil, nil, err)
+				continue
+			}
 
-		event, err := Deserialize(serialized)
+			partitions[partition(event.ObjectId(), workers)] <- receivedEvent{
+				event:    event,
+				envelope: envelope,
+				message:  message,
+			}
+		}
+	}
+
+	return ctx.Err()
+}
+
+// receive returns the next messages, several at once when the queue is a services.BatchQueueService
+func (r *RemoteEventListener) receive(ctx context.Context) ([]services.Message, error) {
+	if queue, ok := r.queue.(services.AckQueueService); ok {
+		message, err := queue.Receive(ctx)
 		if err != nil {
-			r.errChan <- err
-			continue
+			return nil, err
 		}
+		return []services.Message{message}, nil
+	}
+
+	if queue, ok := r.queue.(services.BatchQueueService); ok && r.BatchSize > 1 {
+		bodies, err := queue.PopBatch(ctx, r.BatchSize)
+		if err != nil {
+			return nil, err
+		}
+		messages := make([]services.Message, len(bodies))
+		for i, body := range bodies {
+			messages[i] = services.Message{Body: body}
+		}
+		return messages, nil
+	}
+
+	body, err := r.queue.Pop(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []services.Message{{Body: body}}, nil
+}
+
+// settle acknowledges a processed message when the queue supports it.
+// A failed message is given back to the queue until it exceeds MaxDeliveries, it is then dead lettered.
+// It is not bound to ctx so that in-flight messages are still settled on shutdown.
+func (r *RemoteEventListener) settle(ctx context.Context, message services.Message, event *Event, handler EventHandler, handleErr error) {
+	queue, ok := r.queue.(services.AckQueueService)
+	if !ok {
+		return
+	}
+
+	var err error
+	switch {
+	case handleErr == nil:
+		err = queue.Ack(context.Background(), message)
+	case r.MaxDeliveries > 0 && message.Deliveries >= r.MaxDeliveries:
+		err = r.deadLetter(ctx, message, event, handler, handleErr)
+		if err == nil {
+			err = queue.Ack(context.Background(), message)
+		} else if nackErr := queue.Nack(context.Background(), message); nackErr != nil {
+			r.report(ctx, nackErr)
+		}
+	default:
+		err = queue.Nack(context.Background(), message)
+	}
+	if err != nil {
+		r.report(ctx, err)
+	}
+}
+
+// deadLetter stores the event of a message exceeding MaxDeliveries.
+// Messages that could not be decoded are only reported before being dropped.
+func (r *RemoteEventListener) deadLetter(ctx context.Context, message services.Message, event *Event, handler EventHandler, err error) error {
+	if event == nil {
+		r.report(ctx, fmt.Errorf("dropping message %s after %d deliveries : %w", message.ID, message.Deliveries, err))
+		return nil
+	}
+	if r.DeadLetters == nil {
+		r.report(ctx, fmt.Errorf("dropping event %s after %d deliveries : %w", event.Id(), message.Deliveries, err))
+		return nil
+	}
+
+	letter := NewDeadLetter(*event, handler, err, message.Deliveries)
+	if storeErr := r.DeadLetters.Store(context.Background(), letter); storeErr != nil {
+		return fmt.Errorf("could not dead letter event %s : %w", event.Id(), storeErr)
+	}
+	return nil
+}
+
+// report sends err to the error channel, if any, unless ctx is done
+func (r *RemoteEventListener) report(ctx context.Context, err error) {
+	if r.errChan == nil {
+		return
+	}
+	select {
+	case r.errChan <- err:
+	case <-ctx.Done():
+	}
+}
+
+// detachedContext keeps the values of its parent but is never cancelled
+type detachedContext struct {
+	parent context.Context
+}
+
+// detach returns a context with the values of ctx that is not cancelled with it
+func detach(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (c detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (c detachedContext) Err() error {
+	return nil
+}
 
-		r.receiver.OnEvent(event)
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+func partition(objectID string, partitions int) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(objectID))
+	return int(hash.Sum32() % uint32(partitions))
+}
+
+// handle dispatches event unless the inbox knows it was already processed.
+// Handlers and the inbox get handlerCtx while errors are reported until ctx is done.
+// It returns the last failing handler and its error.
+func (r *RemoteEventListener) handle(ctx context.Context, handlerCtx context.Context, event Event) (EventHandler, error) {
+	if r.Inbox == nil || event.Id() == "" {
+		return r.dispatch(ctx, handlerCtx, event)
+	}
+
+	processed, err := r.Inbox.Processed(handlerCtx, event.Id())
+	if err != nil {
+		r.report(ctx, err)
+		return nil, err
+	}
+	if processed {
+		return nil, nil
+	}
+
+	if handler, err := r.dispatch(ctx, handlerCtx, event); err != nil {
+		return handler, err
+	}
+	if err := r.Inbox.MarkProcessed(handlerCtx, event.Id()); err != nil {
+		r.report(ctx, err)
+	}
+	return nil, nil
+}
+
+func (r *RemoteEventListener) dispatch(ctx context.Context, handlerCtx context.Context, event Event) (EventHandler, error) {
+	r.mutex.RLock()
+	subscriptions := r.subscriptions
+	r.mutex.RUnlock()
+
+	var failed EventHandler
+	var failure error
+	for _, sub := range subscriptions {
+		if !matchesAll(sub.filters, event) {
+			continue
+		}
+		if err := safeHandle(handlerCtx, sub.handler, event); err != nil {
+			r.report(ctx, err)
+			failed, failure = sub.handler, err
+		}
 	}
+	return failed, failure
 }
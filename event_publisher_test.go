@@ -1,7 +1,9 @@
 package goddd
 
 import (
+	"context"
 	"errors"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,13 +16,23 @@ import (
 )
 
 type testReceiver struct {
+	mutex  sync.Mutex
 	events []Event
 }
 
 func (r *testReceiver) OnEvent(event Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 	r.events = append(r.events, event)
 }
 
+// received returns a copy of the events received so far, it may be called while events are delivered
+func (r *testReceiver) received() []Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
 func TestPublished(t *testing.T) {
 	publisher := NewEventPublisher()
 	publisher.Wait = true
@@ -32,8 +44,8 @@ func TestPublished(t *testing.T) {
 	event := NewEvent("TestObject", "name", 1, []byte{1, 2})
 	publisher.Publish([]Event{event})
 
-	assert.Len(t, receiver.events, 1)
-	assertEventsEqual(t, event, receiver.events[0])
+	assert.Len(t, receiver.received(), 1)
+	assertEventsEqual(t, event, receiver.received()[0])
 
 }
 
@@ -48,8 +60,8 @@ func TestOnEvent(t *testing.T) {
 	event := NewEvent("TestObject", "name", 1, []byte{1, 2})
 	publisher.OnEvent(event)
 
-	assert.Len(t, receiver.events, 1)
-	assertEventsEqual(t, event, receiver.events[0])
+	assert.Len(t, receiver.received(), 1)
+	assertEventsEqual(t, event, receiver.received()[0])
 }
 
 func TestMultipleReceivers(t *testing.T) {
@@ -68,10 +80,10 @@ func TestMultipleReceivers(t *testing.T) {
 	event := NewEvent("TestObject", "name", 1, []byte{1, 2})
 	publisher.Publish([]Event{event})
 
-	assert.Len(t, receiver1.events, 1)
-	assertEventsEqual(t, event, receiver1.events[0])
-	assert.Len(t, receiver2.events, 1)
-	assertEventsEqual(t, event, receiver2.events[0])
+	assert.Len(t, receiver1.received(), 1)
+	assertEventsEqual(t, event, receiver1.received()[0])
+	assert.Len(t, receiver2.received(), 1)
+	assertEventsEqual(t, event, receiver2.received()[0])
 }
 
 func TestMultiplePublishAndReceivers(t *testing.T) {
@@ -91,8 +103,432 @@ func TestMultiplePublishAndReceivers(t *testing.T) {
 		publisher.Publish([]Event{NewEvent("TestObject", "name", 1, []byte{3, 4}), NewEvent("TestObject", "another", 2, []byte{1, 2})})
 	}
 
-	assert.Len(t, receiver1.events, 200)
-	assert.Len(t, receiver2.events, 200)
+	assert.Len(t, receiver1.received(), 200)
+	assert.Len(t, receiver2.received(), 200)
+}
+
+type failingHandler struct {
+	failures int
+	calls    int
+}
+
+func (h *failingHandler) HandleEvent(ctx context.Context, event Event) error {
+	h.calls++
+	if h.calls <= h.failures {
+		return errors.New("projection failed")
+	}
+	return nil
+}
+
+func TestHandlerRetried(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.Wait = true
+	publisher.Retry = RetryPolicy{MaxRetries: 3, Backoff: ConstantBackoff(time.Millisecond)}
+	handler := failingHandler{failures: 2}
+
+	publisher.RegisterHandler(&handler)
+	publisher.Publish([]Event{NewEvent("TestObject", "name", 1, []byte{1, 2})})
+
+	assert.Equal(t, 3, handler.calls)
+}
+
+func TestHandlerDeadLettered(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.Wait = true
+	publisher.Retry = RetryPolicy{MaxRetries: 2}
+	publisher.DeadLetters = NewInMemoryDeadLetterStore()
+	handler := failingHandler{failures: 10}
+
+	publisher.RegisterHandler(&handler)
+	event := NewEvent("TestObject", "name", 1, []byte{1, 2})
+	publisher.Publish([]Event{event})
+
+	letters, err := publisher.DeadLetters.List(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, handler.calls)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, event.Id(), letters[0].Event.Id())
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.Equal(t, "projection failed", letters[0].Error)
+}
+
+func TestHandlerErrorReported(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.Wait = true
+	errs := make([]error, 0)
+	publisher.OnError = func(event Event, err error) {
+		errs = append(errs, err)
+	}
+
+	publisher.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		return errors.New("bam")
+	}))
+	publisher.Publish([]Event{NewEvent("TestObject", "name", 1, []byte{1, 2})})
+
+	assert.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "bam")
+}
+
+func TestFilteredReceivers(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.Wait = true
+	students := testReceiver{
+		events: make([]Event, 0),
+	}
+	grades := testReceiver{
+		events: make([]Event, 0),
+	}
+	recent := testReceiver{
+		events: make([]Event, 0),
+	}
+
+	publisher.Register(&students, ForObjectType("Student"))
+	publisher.Register(&grades, ForEventNames("GradeSet"), ForObjectType("Student"))
+	publisher.Register(&recent, func(event Event) bool { return event.Version() > 1 })
+
+	studentID := NewIdentity("Student")
+	publisher.Publish([]Event{
+		NewEvent(studentID, "GradeSet", 1, []byte{1, 2}),
+		NewEvent(studentID, "NameSet", 2, []byte{1, 2}),
+		NewEvent(NewIdentity("Teacher"), "GradeSet", 1, []byte{1, 2}),
+	})
+
+	assert.Len(t, students.received(), 2)
+	assert.Len(t, grades.received(), 1)
+	assert.Equal(t, "GradeSet", grades.received()[0].Name())
+	assert.Len(t, recent.received(), 1)
+	assert.Equal(t, "NameSet", recent.received()[0].Name())
+}
+
+func TestCloseDrainsPendingEvents(t *testing.T) {
+	publisher := NewEventPublisher()
+	var mutex sync.Mutex
+	received := 0
+	publisher.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		time.Sleep(time.Millisecond)
+		mutex.Lock()
+		defer mutex.Unlock()
+		received++
+		return nil
+	}))
+
+	for i := 0; i < 50; i++ {
+		publisher.Publish([]Event{NewEvent("TestObject", "name", i, []byte{1, 2})})
+	}
+	err := publisher.Close(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 50, received)
+}
+
+func TestCloseDeadline(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	publisher.Publish([]Event{NewEvent("TestObject", "name", 1, []byte{1, 2})})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := publisher.Close(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestPublishAfterClose(t *testing.T) {
+	publisher := NewEventPublisher()
+	receiver := testReceiver{
+		events: make([]Event, 0),
+	}
+	errs := make([]error, 0)
+	publisher.OnError = func(event Event, err error) {
+		errs = append(errs, err)
+	}
+	publisher.Register(&receiver)
+
+	assert.NoError(t, publisher.Close(context.Background()))
+	publisher.Publish([]Event{NewEvent("TestObject", "name", 1, []byte{1, 2})})
+
+	assert.Len(t, receiver.received(), 0)
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrPublisherClosed)
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	t.Run("Error", func(t *testing.T) {
+		publisher := NewEventPublisher()
+		publisher.BufferSize = 1
+		publisher.Overflow = OverflowError
+		var mutex sync.Mutex
+		errs := make([]error, 0)
+		publisher.OnError = func(event Event, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			errs = append(errs, err)
+		}
+		release := make(chan struct{})
+		publisher.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+			<-release
+			return nil
+		}))
+
+		for i := 0; i < 5; i++ {
+			publisher.Publish([]Event{NewEvent("TestObject", "name", i, []byte{1, 2})})
+			// HACK: let the receiver pick the first event
+			time.Sleep(10 * time.Millisecond)
+		}
+		close(release)
+		assert.NoError(t, publisher.Close(context.Background()))
+
+		assert.Len(t, errs, 3)
+		assert.ErrorIs(t, errs[0], ErrBufferFull)
+	})
+	t.Run("Drop oldest", func(t *testing.T) {
+		publisher := NewEventPublisher()
+		publisher.BufferSize = 1
+		publisher.Overflow = OverflowDropOldest
+		release := make(chan struct{})
+		versions := make([]int, 0)
+		publisher.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+			<-release
+			versions = append(versions, event.Version())
+			return nil
+		}))
+
+		for i := 0; i < 5; i++ {
+			publisher.Publish([]Event{NewEvent("TestObject", "name", i, []byte{1, 2})})
+			// HACK: let the receiver pick the first event
+			time.Sleep(10 * time.Millisecond)
+		}
+		close(release)
+		assert.NoError(t, publisher.Close(context.Background()))
+
+		assert.Equal(t, []int{0, 4}, versions)
+	})
+}
+
+func TestUnsubscribe(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.Wait = true
+	receiver1 := testReceiver{
+		events: make([]Event, 0),
+	}
+	receiver2 := testReceiver{
+		events: make([]Event, 0),
+	}
+
+	subscription := publisher.Register(&receiver1)
+	publisher.Register(&receiver2)
+	publisher.Publish([]Event{NewEvent("TestObject", "name", 1, []byte{1, 2})})
+	subscription.Unsubscribe()
+	subscription.Unsubscribe()
+	publisher.Publish([]Event{NewEvent("TestObject", "name", 2, []byte{1, 2})})
+
+	assert.Len(t, receiver1.received(), 1)
+	assert.Len(t, receiver2.received(), 2)
+}
+
+func TestRegisterScoped(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.Wait = true
+	var mutex sync.Mutex
+	received := 0
+	ctx, cancel := context.WithCancel(context.Background())
+
+	publisher.RegisterScoped(ctx, ReceiverFunc(func(ctx context.Context, event Event) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		received++
+		return nil
+	}))
+	publisher.Publish([]Event{NewEvent("TestObject", "name", 1, []byte{1, 2})})
+	cancel()
+
+	assert.Eventually(t, func() bool {
+		publisher.mutex.RLock()
+		defer publisher.mutex.RUnlock()
+		return len(publisher.subscriptions) == 0
+	}, time.Second, 10*time.Millisecond)
+	publisher.Publish([]Event{NewEvent("TestObject", "name", 2, []byte{1, 2})})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, 1, received)
+}
+
+func TestUnsubscribeFromHandlerWhilePublishBlocks(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.BufferSize = 1
+	var subscription *Subscription
+	handled := make(chan struct{})
+	release := make(chan struct{})
+	subscription = publisher.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		<-release
+		subscription.Unsubscribe()
+		close(handled)
+		return nil
+	}))
+
+	published := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			publisher.Publish([]Event{NewEvent("TestObject", "name", i, []byte{1, 2})})
+		}
+		close(published)
+	}()
+	// HACK: let Publish block on the full buffer
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("Unsubscribe deadlocked")
+	}
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Publish deadlocked")
+	}
+}
+
+func TestReentrantPublishWait(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.Wait = true
+	var mutex sync.Mutex
+	names := make([]string, 0)
+	publisher.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		mutex.Lock()
+		names = append(names, event.Name())
+		mutex.Unlock()
+		if event.Name() == "first" {
+			publisher.PublishContext(ctx, []Event{NewEvent("TestObject", "second", 1, []byte{})})
+		}
+		return nil
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		publisher.Publish([]Event{NewEvent("TestObject", "first", 0, []byte{})})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Publish deadlocked")
+	}
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(names) == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestReentrantPublishWaitsForOtherReceivers(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.Wait = true
+	other := testReceiver{}
+	publisher.Register(&other, ForEventNames("second"))
+	var received []Event
+	publisher.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		publisher.PublishContext(ctx, []Event{NewEvent("TestObject", "second", 1, []byte{})})
+		received = other.received()
+		return nil
+	}), ForEventNames("first"))
+
+	publisher.Publish([]Event{NewEvent("TestObject", "first", 0, []byte{})})
+
+	assert.Len(t, received, 1)
+}
+
+func TestReentrantPublishFullBuffer(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.BufferSize = 2
+	var mutex sync.Mutex
+	handled := 0
+	publisher.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		mutex.Lock()
+		handled++
+		mutex.Unlock()
+		if event.Name() == "first" {
+			publisher.PublishContext(ctx, []Event{NewEvent(event.ObjectId(), "second", 1, []byte{})})
+		}
+		return nil
+	}))
+
+	go func() {
+		for i := 0; i < 10; i++ {
+			publisher.Publish([]Event{NewEvent("TestObject", "first", 0, []byte{})})
+		}
+	}()
+
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return handled == 20
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestReentrantSaveWait(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.Wait = true
+	repo := NewInMemoryRepository[*Student](&publisher)
+	nested := Student{ID: NewIdentity("Student")}
+	errs := make(chan error, 1)
+	publisher.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		if event.ObjectId() != nested.ID {
+			nested.SetGrade("b")
+			errs <- repo.Save(ctx, &nested)
+		}
+		return nil
+	}))
+
+	object := Student{ID: NewIdentity("Student")}
+	object.SetGrade("a")
+	assert.NoError(t, repo.Save(context.Background(), &object))
+
+	assert.NoError(t, <-errs)
+}
+
+func TestRegisterAfterClose(t *testing.T) {
+	publisher := NewEventPublisher()
+	assert.NoError(t, publisher.Close(context.Background()))
+
+	handled := false
+	subscription := publisher.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		handled = true
+		return nil
+	}))
+	publisher.Publish([]Event{NewEvent("TestObject", "name", 1, []byte{1, 2})})
+	subscription.Unsubscribe()
+
+	assert.NoError(t, publisher.Close(context.Background()))
+	assert.False(t, handled)
+}
+
+func TestConcurrentRegisterAndPublish(t *testing.T) {
+	publisher := NewEventPublisher()
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			subscription := publisher.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+				return nil
+			}))
+			subscription.Unsubscribe()
+		}()
+		go func(i int) {
+			defer wg.Done()
+			publisher.Publish([]Event{NewEvent("TestObject", "name", i, []byte{1, 2})})
+		}(i)
+	}
+	wg.Wait()
+
+	assert.NoError(t, publisher.Close(context.Background()))
+	assert.Len(t, publisher.subscriptions, 0)
 }
 
 func TestRemotePublish(t *testing.T) {
@@ -107,7 +543,9 @@ func TestRemotePublish(t *testing.T) {
 		remotePublisher := NewRemoteEventPublisher(queue, errChan)
 		remoteListener := NewRemoteEventListener(queue, &receiver, errChan)
 
-		go remoteListener.Listen()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go remoteListener.Listen(ctx)
 		event := NewEvent("TestObject", "name", 1, []byte{1, 2})
 		remotePublisher.OnEvent(event)
 
@@ -115,8 +553,8 @@ func TestRemotePublish(t *testing.T) {
 		time.Sleep(time.Second)
 
 		assert.Len(t, errChan, 0)
-		assert.Len(t, receiver.events, 1)
-		assertEventsEqual(t, event, receiver.events[0])
+		assert.Len(t, receiver.received(), 1)
+		assertEventsEqual(t, event, receiver.received()[0])
 	})
 }
 
@@ -132,7 +570,9 @@ func TestRemotePublishMultiple(t *testing.T) {
 		remotePublisher := NewRemoteEventPublisher(queue, errChan)
 		remoteListener := NewRemoteEventListener(queue, &receiver, errChan)
 
-		go remoteListener.Listen()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go remoteListener.Listen(ctx)
 		event1 := NewEvent("TestObject", "name", 1, []byte{1, 2})
 		event2 := NewEvent("TestObject2", "name", 1, []byte{1, 2})
 		remotePublisher.OnEvent(event1)
@@ -142,12 +582,234 @@ func TestRemotePublishMultiple(t *testing.T) {
 		time.Sleep(time.Second)
 
 		assert.Len(t, errChan, 0)
-		assert.Len(t, receiver.events, 2)
-		assertEventsEqual(t, event1, receiver.events[0])
-		assertEventsEqual(t, event2, receiver.events[1])
+		assert.Len(t, receiver.received(), 2)
+		assertEventsEqual(t, event1, receiver.received()[0])
+		assertEventsEqual(t, event2, receiver.received()[1])
+	})
+}
+
+func TestRemotePublishInMemory(t *testing.T) {
+	queue := services.NewInMemoryQueueBroker(0).Queue("test")
+
+	errChan := make(chan error, 1)
+	received := make(chan Event, 2)
+
+	remotePublisher := NewRemoteEventPublisher(queue, errChan)
+	remoteListener := NewRemoteEventListener(queue, nil, errChan)
+	remoteListener.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		received <- event
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go remoteListener.Listen(ctx)
+	first := NewEvent("TestObject", "name", 1, []byte{1, 2})
+	second := NewEvent("TestObject", "name", 2, []byte{3, 4})
+	remotePublisher.OnEvent(first)
+	remotePublisher.OnEvent(second)
+
+	assertEventsEqual(t, first, <-received)
+	assertEventsEqual(t, second, <-received)
+	assert.Len(t, errChan, 0)
+}
+
+type batchRecorder struct {
+	mutex   sync.Mutex
+	gate    chan struct{}
+	batches []int
+}
+
+func (r *batchRecorder) HandleEvent(ctx context.Context, event Event) error {
+	<-r.gate
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.batches = append(r.batches, 1)
+	return nil
+}
+
+func (r *batchRecorder) HandleEvents(ctx context.Context, events []Event) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.batches = append(r.batches, len(events))
+	return nil
+}
+
+func TestBatchHandler(t *testing.T) {
+	publisher := NewEventPublisher()
+	handler := &batchRecorder{gate: make(chan struct{})}
+	publisher.RegisterHandler(handler)
+
+	publisher.OnEvent(NewEvent("TestObject", "name", 1, []byte{1, 2}))
+	// HACK: wait for the first event to be handled alone
+	time.Sleep(50 * time.Millisecond)
+	for i := 2; i <= 5; i++ {
+		publisher.OnEvent(NewEvent("TestObject", "name", i, []byte{1, 2}))
+	}
+	close(handler.gate)
+	assert.NoError(t, publisher.Close(context.Background()))
+
+	assert.Equal(t, []int{1, 4}, handler.batches)
+}
+
+type batchCountingQueue struct {
+	*services.InMemoryQueueService
+	pushBatches int
+}
+
+func (q *batchCountingQueue) PushBatch(ctx context.Context, values [][]byte) error {
+	q.pushBatches++
+	return q.InMemoryQueueService.PushBatch(ctx, values)
+}
+
+func TestRemotePublishBatch(t *testing.T) {
+	queue := &batchCountingQueue{InMemoryQueueService: services.NewInMemoryQueueService(0)}
+
+	errChan := make(chan error, 1)
+	receiver := testReceiver{
+		events: make([]Event, 0),
+	}
+
+	remotePublisher := NewRemoteEventPublisher(queue, errChan)
+	remoteListener := NewRemoteEventListener(queue, &receiver, errChan)
+	events := make([]Event, 0)
+	for i := 1; i <= 5; i++ {
+		events = append(events, NewEvent("TestObject", "name", i, []byte{1, 2}))
+	}
+	remotePublisher.OnEvents(events)
+	assert.Equal(t, 1, queue.pushBatches)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go remoteListener.Listen(ctx)
+
+	// HACK: wait for events to be processed by listener
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Len(t, errChan, 0)
+	assert.Len(t, receiver.received(), 5)
+	for i, event := range events {
+		assertEventsEqual(t, event, receiver.received()[i])
+	}
+}
+
+func TestRemoteListenerFiltered(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := services.NewRedisQueueService(conn, "test")
+
+		errChan := make(chan error, 1)
+		receiver := testReceiver{
+			events: make([]Event, 0),
+		}
+
+		remotePublisher := NewRemoteEventPublisher(queue, errChan)
+		remoteListener := NewRemoteEventListener(queue, &receiver, errChan, ForEventNames("kept"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go remoteListener.Listen(ctx)
+		event := NewEvent("TestObject", "kept", 1, []byte{1, 2})
+		remotePublisher.OnEvent(NewEvent("TestObject", "skipped", 1, []byte{1, 2}))
+		remotePublisher.OnEvent(event)
+
+		// HACK: wait for events to be processed by listener
+		time.Sleep(time.Second)
+
+		assert.Len(t, errChan, 0)
+		assert.Len(t, receiver.received(), 1)
+		assertEventsEqual(t, event, receiver.received()[0])
+	})
+}
+
+func TestRemoteListenerAcknowledged(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := services.NewRedisReliableQueueService(conn, "test", services.ReliableQueueOptions{
+			MaxDeliveries: 2,
+		})
+
+		errChan := make(chan error, 10)
+		receiver := testReceiver{
+			events: make([]Event, 0),
+		}
+
+		remotePublisher := NewRemoteEventPublisher(queue, errChan)
+		remoteListener := NewRemoteEventListener(queue, &receiver, errChan, ForEventNames("kept"))
+		remoteListener.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+			return errors.New("bam")
+		}), ForEventNames("failing"))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go remoteListener.Listen(ctx)
+		event := NewEvent("TestObject", "kept", 1, []byte{1, 2})
+		remotePublisher.OnEvent(NewEvent("TestObject", "failing", 1, []byte{1, 2}))
+		remotePublisher.OnEvent(event)
+
+		// HACK: wait for events to be processed by listener
+		time.Sleep(time.Second)
+
+		assert.Len(t, errChan, 2)
+		assert.Len(t, receiver.received(), 1)
+		assertEventsEqual(t, event, receiver.received()[0])
+		letters, err := queue.DeadLetters(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, letters, 1)
+		assert.EqualValues(t, 0, conn.LLen(context.Background(), "test:processing").Val())
 	})
 }
 
+func TestRemoteListenerMaxDeliveries(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := services.NewRedisReliableQueueService(conn, "test", services.ReliableQueueOptions{})
+		deadLetters := NewInMemoryDeadLetterStore()
+
+		errChan := make(chan error, 10)
+		remotePublisher := NewRemoteEventPublisher(queue, errChan)
+		remoteListener := NewRemoteEventListener(queue, nil, errChan)
+		remoteListener.MaxDeliveries = 2
+		remoteListener.DeadLetters = deadLetters
+		remoteListener.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+			return errors.New("bam")
+		}))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go remoteListener.Listen(ctx)
+		event := NewEvent("TestObject", "poison", 1, []byte{1, 2})
+		remotePublisher.OnEvent(event)
+
+		// HACK: wait for events to be processed by listener
+		time.Sleep(time.Second)
+
+		assert.Len(t, errChan, 2)
+		letters, err := deadLetters.List(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, letters, 1)
+		assertEventsEqual(t, event, letters[0].Event)
+		assert.Equal(t, 2, letters[0].Attempts)
+		assert.EqualValues(t, 0, conn.LLen(context.Background(), "test").Val())
+		assert.EqualValues(t, 0, conn.LLen(context.Background(), "test:processing").Val())
+	})
+}
+
+func TestRemoteListenerNilBackoff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	queue := mocks.NewMockQueueService(ctrl)
+	queue.EXPECT().Pop(gomock.Any()).AnyTimes().Return(nil, errors.New("boum"))
+
+	errChan := make(chan error, 100)
+	remoteListener := NewRemoteEventListener(queue, &testReceiver{}, errChan)
+	remoteListener.Backoff = nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err := remoteListener.Listen(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// 100 and 200ms default delays
+	assert.Len(t, errChan, 2)
+}
+
 func TestRemotePublishQueueError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	queue := mocks.NewMockQueueService(ctrl)
@@ -177,7 +839,9 @@ func TestRemoteListenerQueueError(t *testing.T) {
 
 	remoteListener := NewRemoteEventListener(queue, &receiver, errChan)
 
-	go remoteListener.Listen()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go remoteListener.Listen(ctx)
 
 	// // HACK: wait for listener
 	time.Sleep(time.Second)
@@ -185,3 +849,148 @@ func TestRemoteListenerQueueError(t *testing.T) {
 	assert.Len(t, errChan, 1)
 	assert.Equal(t, err, <-errChan)
 }
+
+func TestRemoteListenerCancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	queue := mocks.NewMockQueueService(ctrl)
+	queue.EXPECT().Pop(gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	errChan := make(chan error, 1)
+	remoteListener := NewRemoteEventListener(queue, &testReceiver{}, errChan)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- remoteListener.Listen(ctx)
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("listener did not stop")
+	}
+	assert.Len(t, errChan, 0)
+}
+
+func TestRemoteListenerFinishesInFlightMessages(t *testing.T) {
+	queue := services.NewInMemoryQueueService(0)
+	remotePublisher := NewRemoteEventPublisher(queue, nil)
+	remotePublisher.OnEvents([]Event{
+		NewEvent("TestObject", "name", 1, []byte{1, 2}),
+		NewEvent("TestObject", "name", 2, []byte{3, 4}),
+	})
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	errs := make([]error, 0)
+	remoteListener := NewRemoteEventListener(queue, nil, nil)
+	remoteListener.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		started <- struct{}{}
+		<-release
+		mutex.Lock()
+		defer mutex.Unlock()
+		errs = append(errs, ctx.Err())
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- remoteListener.Listen(ctx)
+	}()
+	<-started
+	cancel()
+	close(release)
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("listener did not stop")
+	}
+	assert.Equal(t, []error{nil, nil}, errs)
+}
+
+func TestRemoteListenerBackoff(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	queue := mocks.NewMockQueueService(ctrl)
+
+	var mutex sync.Mutex
+	pops := 0
+	queue.EXPECT().Pop(gomock.Any()).AnyTimes().DoAndReturn(func(ctx context.Context) ([]byte, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		pops++
+		return nil, errors.New("boum")
+	})
+
+	errChan := make(chan error, 100)
+	remoteListener := NewRemoteEventListener(queue, &testReceiver{}, errChan)
+	remoteListener.Backoff = ExponentialBackoff(20*time.Millisecond, time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	remoteListener.Listen(ctx)
+
+	// 20, 40, 80ms delays fit in 200ms, 160ms does not
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, 4, pops)
+	assert.Len(t, errChan, 4)
+}
+
+func TestRemoteListenerWorkersKeepObjectOrder(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := services.NewRedisQueueService(conn, "test")
+		errChan := make(chan error, 1)
+
+		var mutex sync.Mutex
+		versions := make(map[string][]int)
+		remoteListener := NewRemoteEventListener(queue, nil, errChan)
+		remoteListener.Workers = 4
+		remoteListener.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			versions[event.ObjectId()] = append(versions[event.ObjectId()], event.Version())
+			return nil
+		}))
+
+		remotePublisher := NewRemoteEventPublisher(queue, errChan)
+		objectIDs := []string{NewIdentity("Student"), NewIdentity("Student"), NewIdentity("Student")}
+		for version := 0; version < 20; version++ {
+			for _, objectID := range objectIDs {
+				remotePublisher.OnEvent(NewEvent(objectID, "name", version, []byte{1, 2}))
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go remoteListener.Listen(ctx)
+
+		assert.Eventually(t, func() bool {
+			mutex.Lock()
+			defer mutex.Unlock()
+			for _, objectID := range objectIDs {
+				if len(versions[objectID]) != 20 {
+					return false
+				}
+			}
+			return true
+		}, 5*time.Second, 10*time.Millisecond)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		for _, objectID := range objectIDs {
+			for i, version := range versions[objectID] {
+				assert.Equal(t, i, version)
+			}
+		}
+		assert.Len(t, errChan, 0)
+	})
+}
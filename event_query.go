@@ -0,0 +1,157 @@
+package goddd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultQueryLimit is the number of events of a page when EventQuery.Limit is not set
+const DefaultQueryLimit = 100
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EventQuery selects the events of every stream matching all its criteria, ordered by timestamp.
+// Its zero value selects every event.
+type EventQuery struct {
+	// Names keeps the events having one of these names
+	Names []string
+	// ObjectIDPrefix keeps the events of the objects whose ID starts with the prefix
+	ObjectIDPrefix string
+	// ObjectType keeps the events of the objects whose identity was created by NewIdentity with this type
+	ObjectType string
+	// From keeps the events saved at or after From, when set
+	From time.Time
+	// To keeps the events saved before To, when set
+	To time.Time
+	// Limit is the maximum number of events of a page, DefaultQueryLimit by default
+	Limit int
+	// Cursor resumes the query after the last event of a previous page
+	Cursor string
+}
+
+// EventPage is a page of the events matching an EventQuery
+type EventPage struct {
+	Events []Event
+	// NextCursor is the Cursor of the query returning the next page, empty on the last page
+	NextCursor string
+}
+
+// eventPosition is the position of an event in the order of the query results
+type eventPosition struct {
+	Timestamp int64  `json:"t"`
+	ObjectID  string `json:"o"`
+	Version   int    `json:"v"`
+}
+
+func positionOf(event Event) eventPosition {
+	return eventPosition{
+		Timestamp: event.Timestamp(),
+		ObjectID:  event.ObjectId(),
+		Version:   event.Version(),
+	}
+}
+
+func (p eventPosition) before(other eventPosition) bool {
+	if p.Timestamp != other.Timestamp {
+		return p.Timestamp < other.Timestamp
+	}
+	if p.ObjectID != other.ObjectID {
+		return p.ObjectID < other.ObjectID
+	}
+	return p.Version < other.Version
+}
+
+func (p eventPosition) cursor() string {
+	encoded, _ := json.Marshal(p)
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// after returns the position of the query cursor, nil without cursor
+func (q EventQuery) after() (*eventPosition, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	position := eventPosition{}
+	if err := json.Unmarshal(decoded, &position); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &position, nil
+}
+
+func (q EventQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultQueryLimit
+	}
+	return q.Limit
+}
+
+func (q EventQuery) matches(event Event) bool {
+	if event.Name() == ARCHIVED_EVENT_NAME {
+		return false
+	}
+	if len(q.Names) > 0 && !containsString(q.Names, event.Name()) {
+		return false
+	}
+	if !strings.HasPrefix(event.ObjectId(), q.ObjectIDPrefix) {
+		return false
+	}
+	if q.ObjectType != "" && ObjectType(event.ObjectId()) != q.ObjectType {
+		return false
+	}
+	if !q.From.IsZero() && event.Timestamp() < q.From.UnixNano() {
+		return false
+	}
+	if !q.To.IsZero() && event.Timestamp() >= q.To.UnixNano() {
+		return false
+	}
+	return true
+}
+
+// queryEvents pages through events held in memory
+func queryEvents(events []Event, query EventQuery) (EventPage, error) {
+	after, err := query.after()
+	if err != nil {
+		return EventPage{}, err
+	}
+
+	matching := make([]Event, 0)
+	for _, event := range events {
+		if query.matches(event) && (after == nil || after.before(positionOf(event))) {
+			matching = append(matching, event)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		return positionOf(matching[i]).before(positionOf(matching[j]))
+	})
+
+	return newEventPage(matching, query.limit()), nil
+}
+
+// newEventPage builds a page from up to limit+1 events, the extra one telling there is a next page
+func newEventPage(events []Event, limit int) EventPage {
+	if len(events) <= limit {
+		return EventPage{Events: events}
+	}
+	events = events[:limit]
+	return EventPage{
+		Events:     events,
+		NextCursor: positionOf(events[limit-1]).cursor(),
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package goddd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func queryTestEvents() []Event {
+	student := NewIdentity("Student")
+	teacher := NewIdentity("Teacher")
+	march := time.Date(2022, time.March, 10, 0, 0, 0, 0, time.UTC).UnixNano()
+	april := time.Date(2022, time.April, 10, 0, 0, 0, 0, time.UTC).UnixNano()
+	return []Event{
+		ReloadEvent("1", student, "GradeSet", 0, []byte{}, march),
+		ReloadEvent("2", teacher, "GradeSet", 0, []byte{}, march),
+		ReloadEvent("3", student, "NameSet", 1, []byte{}, march+1),
+		ReloadEvent("4", student, "GradeSet", 2, []byte{}, april),
+		ReloadEvent("5", student, ARCHIVED_EVENT_NAME, 3, []byte{}, april),
+	}
+}
+
+func eventIDs(events []Event) []string {
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.Id()
+	}
+	return ids
+}
+
+func TestQueryEvents(t *testing.T) {
+	events := queryTestEvents()
+
+	page, err := queryEvents(events, EventQuery{
+		Names: []string{"GradeSet"},
+		From:  time.Date(2022, time.March, 1, 0, 0, 0, 0, time.UTC),
+		To:    time.Date(2022, time.April, 1, 0, 0, 0, 0, time.UTC),
+	})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"1", "2"}, eventIDs(page.Events))
+	assert.Empty(t, page.NextCursor)
+
+	page, err = queryEvents(events, EventQuery{ObjectType: "Student"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "3", "4"}, eventIDs(page.Events))
+
+	page, err = queryEvents(events, EventQuery{ObjectIDPrefix: "Teach"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2"}, eventIDs(page.Events))
+}
+
+func TestQueryEventsPages(t *testing.T) {
+	events := queryTestEvents()
+	query := EventQuery{Limit: 2}
+
+	ids := make([]string, 0)
+	pages := 0
+	for {
+		page, err := queryEvents(events, query)
+		assert.NoError(t, err)
+		ids = append(ids, eventIDs(page.Events)...)
+		pages++
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	assert.Equal(t, 2, pages)
+	assert.ElementsMatch(t, []string{"1", "2", "3", "4"}, ids)
+	assert.Equal(t, "4", ids[3])
+
+	_, err := queryEvents(events, EventQuery{Cursor: "invalid"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestInMemoryQueryEvents(t *testing.T) {
+	publisher := NewEventPublisher()
+	repo := NewInMemoryRepository[*Student](&publisher)
+	object := Student{ID: NewIdentity("Student")}
+	object.SetGrade("a")
+	object.SetGrade("b")
+	repo.Save(context.Background(), &object)
+
+	page, err := repo.QueryEvents(context.Background(), EventQuery{ObjectType: "Student", Names: []string{"GradeSet"}, Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, page.Events, 1)
+	assert.Equal(t, 0, page.Events[0].Version())
+
+	page, err = repo.QueryEvents(context.Background(), EventQuery{ObjectType: "Student", Names: []string{"GradeSet"}, Limit: 1, Cursor: page.NextCursor})
+	assert.NoError(t, err)
+	assert.Len(t, page.Events, 1)
+	assert.Equal(t, 1, page.Events[0].Version())
+	assert.Empty(t, page.NextCursor)
+}
@@ -9,6 +9,9 @@ import (
 
 const REMOVED_EVENT_NAME = "removed"
 
+// ARCHIVED_EVENT_NAME names the stub left in the event store in place of an archived stream
+const ARCHIVED_EVENT_NAME = "archived_stream"
+
 // EventStream is an interface representing a stream of events
 type EventStream interface {
 	AddEvent(object DomainObject, eventName string, payload msgp.Marshaler) error
@@ -30,8 +33,8 @@ type Stream struct {
 
 // AddEvent add a new event into the stream
 func (s *Stream) AddEvent(object DomainObject, eventName string, payload msgp.Marshaler) error {
-	if strings.ToLower(eventName) == REMOVED_EVENT_NAME {
-		return fmt.Errorf("'%s' is a reserved event name", REMOVED_EVENT_NAME)
+	if name := strings.ToLower(eventName); name == REMOVED_EVENT_NAME || name == ARCHIVED_EVENT_NAME {
+		return fmt.Errorf("'%s' is a reserved event name", name)
 	}
 	bytePayload, err := payload.MarshalMsg(nil)
 	if err != nil {
@@ -53,5 +53,17 @@ func TestSerializeDeserialize(t *testing.T) {
 	reloaded, err := Deserialize(serialized)
 	assert.NoError(t, err)
 
+	assertEventsEqual(t, event, reloaded)
+	assert.Equal(t, event.Id(), reloaded.Id())
+}
+
+func TestSerializeDeserializeBinaryPayload(t *testing.T) {
+	event := NewEvent(uuid.New().String(), "eventCreated", 3, []byte{0x81, 0xff, 0x00, 0xa5})
+	serialized, err := event.Serialize()
+	assert.NoError(t, err)
+
+	reloaded, err := Deserialize(serialized)
+	assert.NoError(t, err)
+
 	assertEventsEqual(t, event, reloaded)
 }
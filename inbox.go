@@ -0,0 +1,59 @@
+package goddd
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// Inbox records the events already processed by a consumer so that redelivered events are skipped
+type Inbox interface {
+	Processed(ctx context.Context, eventID string) (bool, error)
+	MarkProcessed(ctx context.Context, eventID string) error
+}
+
+// InMemoryInbox is an Inbox remembering the most recently processed event IDs
+type InMemoryInbox struct {
+	mutex    sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+// NewInMemoryInbox creates an inbox remembering at most capacity event IDs, the least recently used being forgotten first
+func NewInMemoryInbox(capacity int) *InMemoryInbox {
+	return &InMemoryInbox{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func (i *InMemoryInbox) Processed(ctx context.Context, eventID string) (bool, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	entry, ok := i.entries[eventID]
+	if ok {
+		i.order.MoveToFront(entry)
+	}
+	return ok, nil
+}
+
+func (i *InMemoryInbox) MarkProcessed(ctx context.Context, eventID string) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if entry, ok := i.entries[eventID]; ok {
+		i.order.MoveToFront(entry)
+		return nil
+	}
+
+	i.entries[eventID] = i.order.PushFront(eventID)
+	for i.capacity > 0 && i.order.Len() > i.capacity {
+		oldest := i.order.Back()
+		i.order.Remove(oldest)
+		delete(i.entries, oldest.Value.(string))
+	}
+	return nil
+}
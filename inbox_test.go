@@ -0,0 +1,73 @@
+package goddd
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/owlint/goddd/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryInbox(t *testing.T) {
+	inbox := NewInMemoryInbox(2)
+	ctx := context.Background()
+
+	processed, err := inbox.Processed(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, processed)
+
+	assert.NoError(t, inbox.MarkProcessed(ctx, "a"))
+	assert.NoError(t, inbox.MarkProcessed(ctx, "b"))
+	processed, _ = inbox.Processed(ctx, "a")
+	assert.True(t, processed)
+
+	// b is now the least recently used
+	assert.NoError(t, inbox.MarkProcessed(ctx, "c"))
+	processed, _ = inbox.Processed(ctx, "b")
+	assert.False(t, processed)
+	processed, _ = inbox.Processed(ctx, "a")
+	assert.True(t, processed)
+	processed, _ = inbox.Processed(ctx, "c")
+	assert.True(t, processed)
+}
+
+func TestRemoteListenerInbox(t *testing.T) {
+	queue := services.NewInMemoryQueueService(0)
+	errChan := make(chan error, 10)
+	handled := make(chan Event, 10)
+	var calls int32
+
+	remoteListener := NewRemoteEventListener(queue, nil, errChan)
+	remoteListener.Inbox = NewInMemoryInbox(100)
+	remoteListener.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return errors.New("bam")
+		}
+		handled <- event
+		return nil
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go remoteListener.Listen(ctx)
+
+	event := NewEvent("TestObject", "name", 1, []byte{1, 2})
+	serializedEvent, err := event.Serialize()
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, queue.Push(context.Background(), serializedEvent))
+	}
+
+	// HACK: wait for events to be processed by listener
+	time.Sleep(100 * time.Millisecond)
+
+	assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	assert.Len(t, handled, 1)
+	assert.Len(t, errChan, 1)
+	processed, err := remoteListener.Inbox.Processed(context.Background(), event.Id())
+	assert.NoError(t, err)
+	assert.True(t, processed)
+}
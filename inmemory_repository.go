@@ -3,6 +3,7 @@ package goddd
 import (
 	"context"
 	"errors"
+	"sort"
 	"time"
 )
 
@@ -23,11 +24,29 @@ func (r *InMemoryRepository[T]) Save(ctx context.Context, object T) error {
 
 	r.eventStream = append(r.eventStream, eventToAdd...)
 
-	r.publisher.Publish(eventToAdd)
+	r.publisher.PublishContext(ctx, eventToAdd)
 
 	return nil
 }
 
+func (r *InMemoryRepository[T]) SaveExpecting(ctx context.Context, object T, expectedVersion int) error {
+	exists, err := r.Exists(ctx, object.ObjectID())
+	if err != nil {
+		return err
+	}
+	actualVersion := 0
+	for _, event := range r.objectRepositoryEvents(object.ObjectID()) {
+		if event.Version() >= actualVersion {
+			actualVersion = event.Version() + 1
+		}
+	}
+	if err := checkExpectedVersion(object.ObjectID(), expectedVersion, actualVersion, exists); err != nil {
+		return err
+	}
+
+	return r.Save(ctx, object)
+}
+
 func (r *InMemoryRepository[T]) Load(ctx context.Context, objectID string, object T) error {
 	if exist, err := r.Exists(ctx, objectID); err != nil || !exist {
 		return errors.New("Cannot load unknown object")
@@ -75,10 +94,55 @@ func (r *InMemoryRepository[T]) EventsSince(ctx context.Context, timestamp time.
 	return events, nil
 }
 
+func (r *InMemoryRepository[T]) IterateEventsSince(ctx context.Context, timestamp time.Time, batchSize int) (EventIterator, error) {
+	events := make([]Event, 0)
+	for _, event := range r.eventStream {
+		if event.Timestamp() >= timestamp.UnixNano() {
+			events = append(events, event)
+		}
+	}
+	return newSliceEventIterator(events), nil
+}
+
+func (r *InMemoryRepository[T]) IterateObjectEvents(ctx context.Context, objectID string, sinceVersion int, batchSize int) (EventIterator, error) {
+	events := make([]Event, 0)
+	for _, event := range r.objectRepositoryEvents(objectID) {
+		if event.Version() > sinceVersion {
+			events = append(events, event)
+		}
+	}
+	return newSliceEventIterator(events), nil
+}
+
+func (r *InMemoryRepository[T]) QueryEvents(ctx context.Context, query EventQuery) (EventPage, error) {
+	return queryEvents(r.eventStream, query)
+}
+
+func (r *InMemoryRepository[T]) ListObjectIDs(ctx context.Context, filter ObjectFilter, cursor string, limit int) (ObjectIDPage, error) {
+	after, err := decodeObjectCursor(cursor)
+	if err != nil {
+		return ObjectIDPage{}, err
+	}
+	objectIDs := liveObjectIDs(r.eventStream, filter)
+	start := sort.SearchStrings(objectIDs, after)
+	if start < len(objectIDs) && objectIDs[start] == after {
+		start++
+	}
+	return newObjectIDPage(objectIDs[start:], objectPageLimit(limit)), nil
+}
+
+func (r *InMemoryRepository[T]) Count(ctx context.Context, filter ObjectFilter) (int64, error) {
+	return int64(len(liveObjectIDs(r.eventStream, filter))), nil
+}
+
 func (r *InMemoryRepository[T]) Update(ctx context.Context, objectID string, object T, nbRetries int, updater func(T) (T, error)) (T, error) {
 	return repoUpdate[T](ctx, r, objectID, object, nbRetries, updater)
 }
 
+func (r *InMemoryRepository[T]) LoadMany(ctx context.Context, objectIDs []string, factory func() T) (map[string]T, error) {
+	return repoLoadMany[T](ctx, r, objectIDs, factory)
+}
+
 func (r *InMemoryRepository[T]) Remove(ctx context.Context, objectID string, object T) error {
 	if exists, err := r.Exists(ctx, objectID); err != nil || !exists {
 		return errors.New("unknown object")
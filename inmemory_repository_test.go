@@ -2,6 +2,7 @@ package goddd
 
 import (
 	"context"
+	"sort"
 	"testing"
 	"time"
 
@@ -192,3 +193,143 @@ func TestInMemoryRemove(t *testing.T) {
 		assert.True(t, exists)
 	})
 }
+
+func TestLoadMany(t *testing.T) {
+	publisher := NewEventPublisher()
+	repo := NewInMemoryRepository[*Student](&publisher)
+	object := Student{ID: uuid.New().String()}
+	object2 := Student{ID: uuid.New().String()}
+	unknown := uuid.New().String()
+
+	object.SetGrade("a")
+	repo.Save(context.Background(), &object)
+	object2.SetGrade("b")
+	repo.Save(context.Background(), &object2)
+
+	objects, err := repo.LoadMany(context.Background(), []string{object.ID, object2.ID, unknown}, func() *Student {
+		return &Student{}
+	})
+
+	var missing *MissingObjectsError
+	assert.ErrorAs(t, err, &missing)
+	assert.Equal(t, []string{unknown}, missing.ObjectIDs)
+	assert.Len(t, objects, 2)
+	assert.Equal(t, "a", objects[object.ID].grade)
+	assert.Equal(t, "b", objects[object2.ID].grade)
+}
+
+func TestInMemoryIterateObjectEvents(t *testing.T) {
+	publisher := NewEventPublisher()
+	repo := NewInMemoryRepository[*Student](&publisher)
+	object := Student{ID: uuid.New().String()}
+	other := Student{ID: uuid.New().String()}
+
+	object.SetGrade("a")
+	object.SetGrade("b")
+	object.SetGrade("c")
+	repo.Save(context.Background(), &object)
+	other.SetGrade("d")
+	repo.Save(context.Background(), &other)
+
+	events, err := repo.IterateObjectEvents(context.Background(), object.ID, 0, 10)
+	assert.NoError(t, err)
+	defer events.Close(context.Background())
+
+	versions := make([]int, 0)
+	for events.Next(context.Background()) {
+		assert.Equal(t, object.ID, events.Event().ObjectId())
+		versions = append(versions, events.Event().Version())
+	}
+	assert.NoError(t, events.Err())
+	assert.Equal(t, []int{1, 2}, versions)
+}
+
+func TestInMemoryIterateEventsSinceCancelled(t *testing.T) {
+	publisher := NewEventPublisher()
+	repo := NewInMemoryRepository[*Student](&publisher)
+	object := Student{}
+	before := time.Now()
+
+	object.SetGrade("a")
+	object.SetGrade("b")
+	repo.Save(context.Background(), &object)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := repo.IterateEventsSince(ctx, before, 1)
+	assert.NoError(t, err)
+	defer events.Close(context.Background())
+
+	assert.True(t, events.Next(ctx))
+	cancel()
+	assert.False(t, events.Next(ctx))
+	assert.ErrorIs(t, events.Err(), context.Canceled)
+}
+
+func TestInMemorySaveExpecting(t *testing.T) {
+	publisher := NewEventPublisher()
+	repo := NewInMemoryRepository[*Student](&publisher)
+	object := Student{ID: uuid.New().String()}
+
+	object.SetGrade("a")
+	assert.ErrorIs(t, repo.SaveExpecting(context.Background(), &object, StreamExists), ConcurrencyError)
+	assert.NoError(t, repo.SaveExpecting(context.Background(), &object, NoStream))
+
+	object.SetGrade("b")
+	err := repo.SaveExpecting(context.Background(), &object, 0)
+	var conflict *VersionConflictError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, object.ID, conflict.ObjectID)
+	assert.Equal(t, 0, conflict.Expected)
+	assert.Equal(t, 1, conflict.Actual)
+	assert.EqualError(t, err, "concurrency error while saving : "+object.ID+" expected at version 0, got 1")
+
+	assert.NoError(t, repo.SaveExpecting(context.Background(), &object, 1))
+	object.SetGrade("c")
+	assert.NoError(t, repo.SaveExpecting(context.Background(), &object, Any))
+	assert.Len(t, repo.eventStream, 3)
+}
+
+func TestInMemoryListObjectIDs(t *testing.T) {
+	publisher := NewEventPublisher()
+	repo := NewInMemoryRepository[*Student](&publisher)
+	students := make([]string, 0)
+	for i := 0; i < 5; i++ {
+		object := Student{ID: NewIdentity("Student")}
+		object.SetGrade("a")
+		object.SetGrade("b")
+		repo.Save(context.Background(), &object)
+		students = append(students, object.ID)
+	}
+	teacher := Student{ID: NewIdentity("Teacher")}
+	teacher.SetGrade("a")
+	repo.Save(context.Background(), &teacher)
+	removed := Student{ID: NewIdentity("Student")}
+	removed.SetGrade("a")
+	repo.Save(context.Background(), &removed)
+	assert.NoError(t, repo.Remove(context.Background(), removed.ID, &removed))
+
+	count, err := repo.Count(context.Background(), ObjectFilter{ObjectType: "Student"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+	count, err = repo.Count(context.Background(), ObjectFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6), count)
+
+	listed := make([]string, 0)
+	cursor := ""
+	for {
+		page, err := repo.ListObjectIDs(context.Background(), ObjectFilter{ObjectIDPrefix: "Student-"}, cursor, 2)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(page.ObjectIDs), 2)
+		listed = append(listed, page.ObjectIDs...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	sort.Strings(students)
+	assert.Equal(t, students, listed)
+
+	_, err = repo.ListObjectIDs(context.Background(), ObjectFilter{}, "%", 2)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
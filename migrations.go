@@ -0,0 +1,378 @@
+package goddd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrMigrationLocked = errors.New("migrations are locked by another process")
+var ErrDuplicateMigration = errors.New("duplicate migration version")
+
+// MigrationFunc applies a migration to a database
+type MigrationFunc func(ctx context.Context, database *mongo.Database) error
+
+// Migration is a numbered change of a Mongo database. Versions are applied in increasing order, once.
+type Migration struct {
+	Version     int
+	Description string
+	Up          MigrationFunc
+}
+
+// AppliedMigration is the record of a migration applied by a Migrator
+type AppliedMigration struct {
+	ID          string    `bson:"_id"`
+	Scope       string    `bson:"scope"`
+	Version     int       `bson:"version"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedat"`
+}
+
+// Migrator applies the pending migrations of a scope, recording them in a migrations collection.
+// A lock document in the same collection prevents concurrent runs of the same scope.
+type Migrator struct {
+	database   *mongo.Database
+	scope      string
+	migrations []Migration
+
+	// Collection records the applied migrations and the locks, "migrations" by default
+	Collection string
+	// LockTTL is how long a lock is held before being considered abandoned.
+	// It is extended every third of LockTTL while the migrations run.
+	LockTTL time.Duration
+	// LockWait is how long Migrate waits for a lock held by another process before returning ErrMigrationLocked
+	LockWait time.Duration
+}
+
+func NewMigrator(database *mongo.Database, scope string, migrations []Migration) *Migrator {
+	return &Migrator{
+		database:   database,
+		scope:      scope,
+		migrations: migrations,
+		Collection: "migrations",
+		LockTTL:    10 * time.Minute,
+		LockWait:   time.Minute,
+	}
+}
+
+// Migrate applies the pending migrations in version order and returns the versions applied.
+// The scope lock is only taken when migrations are pending, they are checked again once it is held.
+func (m *Migrator) Migrate(ctx context.Context) ([]int, error) {
+	migrations, err := sortMigrations(m.migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return []int{}, nil
+	}
+
+	release, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int]bool, len(applied))
+	for _, migration := range applied {
+		done[migration.Version] = true
+	}
+
+	versions := make([]int, 0)
+	for _, migration := range migrations {
+		if done[migration.Version] {
+			continue
+		}
+		if err := migration.Up(ctx, m.database); err != nil {
+			return versions, fmt.Errorf("migration %d (%s) failed : %w", migration.Version, migration.Description, err)
+		}
+
+		_, err := m.collection().InsertOne(ctx, AppliedMigration{
+			ID:          fmt.Sprintf("%s:%d", m.scope, migration.Version),
+			Scope:       m.scope,
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   time.Now(),
+		})
+		if err != nil {
+			return versions, err
+		}
+		versions = append(versions, migration.Version)
+	}
+
+	return versions, nil
+}
+
+// Applied returns the migrations already applied, by version
+func (m *Migrator) Applied(ctx context.Context) ([]AppliedMigration, error) {
+	filter := bson.D{{Key: "scope", Value: m.scope}, {Key: "version", Value: bson.D{{Key: "$exists", Value: true}}}}
+	cursor, err := m.collection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "version", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	applied := make([]AppliedMigration, 0)
+	err = cursor.All(ctx, &applied)
+	return applied, err
+}
+
+// Pending returns the migrations not applied yet, by version
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	migrations, err := sortMigrations(m.migrations)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int]bool, len(applied))
+	for _, migration := range applied {
+		done[migration.Version] = true
+	}
+
+	pending := make([]Migration, 0)
+	for _, migration := range migrations {
+		if !done[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) collection() *mongo.Collection {
+	return m.database.Collection(m.Collection)
+}
+
+// lock takes the scope lock, waiting up to LockWait for another process to release it.
+// The lock is extended in the background until released.
+func (m *Migrator) lock(ctx context.Context) (func(), error) {
+	lockID := "lock:" + m.scope
+	owner := uuid.NewString()
+	deadline := time.Now().Add(m.LockWait)
+
+	for {
+		now := time.Now()
+		_, err := m.collection().UpdateOne(
+			ctx,
+			bson.D{{Key: "_id", Value: lockID}, {Key: "lockeduntil", Value: bson.D{{Key: "$lt", Value: now}}}},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "owner", Value: owner}, {Key: "lockeduntil", Value: now.Add(m.LockTTL)}}}},
+			options.Update().SetUpsert(true),
+		)
+		if err == nil {
+			break
+		}
+		// The lock document exists and has not expired
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrMigrationLocked
+		}
+		if err := sleep(ctx, 500*time.Millisecond); err != nil {
+			return nil, err
+		}
+	}
+
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go m.extendLock(lockID, owner, stop, stopped)
+
+	return func() {
+		close(stop)
+		<-stopped
+		m.collection().DeleteOne(context.Background(), bson.D{{Key: "_id", Value: lockID}, {Key: "owner", Value: owner}})
+	}, nil
+}
+
+// extendLock pushes the lock expiry back every third of LockTTL until stop is closed
+func (m *Migrator) extendLock(lockID, owner string, stop <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+
+	interval := m.LockTTL / 3
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.collection().UpdateOne(
+				context.Background(),
+				bson.D{{Key: "_id", Value: lockID}, {Key: "owner", Value: owner}},
+				bson.D{{Key: "$set", Value: bson.D{{Key: "lockeduntil", Value: time.Now().Add(m.LockTTL)}}}},
+			)
+		}
+	}
+}
+
+func sortMigrations(migrations []Migration) ([]Migration, error) {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Version == sorted[i-1].Version {
+			return nil, fmt.Errorf("%w : %d", ErrDuplicateMigration, sorted[i].Version)
+		}
+	}
+	return sorted, nil
+}
+
+// EventStoreMigrations are the migrations of the collections used by a MongoRepository
+func EventStoreMigrations(eventsCollection, snapshotsCollection string) []Migration {
+	return []Migration{
+		{
+			Version:     1,
+			Description: "create event store indexes",
+			Up: func(ctx context.Context, database *mongo.Database) error {
+				return createEventStoreIndexes(ctx, database.Collection(eventsCollection))
+			},
+		},
+		{
+			Version:     2,
+			Description: "index snapshots by object ID",
+			Up: CreateIndexes(snapshotsCollection, mongo.IndexModel{
+				Keys:    bson.D{{Key: "objectid", Value: 1}},
+				Options: options.Index().SetName("objectID"),
+			}),
+		},
+		{
+			Version:     3,
+			Description: "index events by name and timestamp for queries",
+			Up: CreateIndexes(
+				eventsCollection,
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "name", Value: 1}, {Key: "timestamp", Value: 1}, {Key: "objectid", Value: 1}, {Key: "version", Value: 1}},
+					Options: options.Index().SetName("name_timestamp"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "timestamp", Value: 1}, {Key: "objectid", Value: 1}, {Key: "version", Value: 1}},
+					Options: options.Index().SetName("timestamp_objectID_version"),
+				},
+			),
+		},
+		{
+			Version:     4,
+			Description: "index events by name and object ID to list archived objects",
+			Up: CreateIndexes(eventsCollection, mongo.IndexModel{
+				Keys:    bson.D{{Key: "name", Value: 1}, {Key: "objectid", Value: 1}},
+				Options: options.Index().SetName("name_objectID"),
+			}),
+		},
+	}
+}
+
+// CreateIndexes returns a migration creating indexes on a collection
+func CreateIndexes(collection string, indexes ...mongo.IndexModel) MigrationFunc {
+	return func(ctx context.Context, database *mongo.Database) error {
+		_, err := database.Collection(collection).Indexes().CreateMany(ctx, indexes)
+		return err
+	}
+}
+
+// DropIndex returns a migration dropping a named index of a collection
+func DropIndex(collection, name string) MigrationFunc {
+	return func(ctx context.Context, database *mongo.Database) error {
+		_, err := database.Collection(collection).Indexes().DropOne(ctx, name)
+		return err
+	}
+}
+
+// RenameField returns a migration renaming a field in every document of a collection
+func RenameField(collection, from, to string) MigrationFunc {
+	return Backfill(
+		collection,
+		bson.D{{Key: from, Value: bson.D{{Key: "$exists", Value: true}}}},
+		bson.D{{Key: "$rename", Value: bson.D{{Key: from, Value: to}}}},
+	)
+}
+
+// Backfill returns a migration applying update to the documents of a collection matching filter
+func Backfill(collection string, filter, update interface{}) MigrationFunc {
+	return func(ctx context.Context, database *mongo.Database) error {
+		_, err := database.Collection(collection).UpdateMany(ctx, filter, update)
+		return err
+	}
+}
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(.+)\.json$`)
+
+// LoadMigrations reads the migrations of a directory. Each NNN_description.json file holds
+// a JSON array of database commands in MongoDB extended JSON, run in order by the migration.
+// A missing directory holds no migrations.
+func LoadMigrations(dir string) ([]Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Migration{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		file := struct {
+			Commands []bson.D `bson:"commands"`
+		}{}
+		wrapped := append(append([]byte(`{"commands":`), content...), '}')
+		if err := bson.UnmarshalExtJSON(wrapped, false, &file); err != nil {
+			return nil, fmt.Errorf("invalid migration %s : %w", entry.Name(), err)
+		}
+
+		commands := file.Commands
+		migrations = append(migrations, Migration{
+			Version:     version,
+			Description: match[2],
+			Up: func(ctx context.Context, database *mongo.Database) error {
+				for _, command := range commands {
+					if err := database.RunCommand(ctx, command).Err(); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		})
+	}
+
+	return sortMigrations(migrations)
+}
@@ -0,0 +1,206 @@
+package goddd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "002_rename_grade.json"), []byte(`[{"ping": 1}]`), 0o644)
+	os.WriteFile(filepath.Join(dir, "001_create_views.json"), []byte(`[{"create": "views"}, {"ping": {"$numberInt": "1"}}]`), 0o644)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a migration"), 0o644)
+
+	migrations, err := LoadMigrations(dir)
+
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, 1, migrations[0].Version)
+	assert.Equal(t, "create_views", migrations[0].Description)
+	assert.Equal(t, 2, migrations[1].Version)
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	migrations, err := LoadMigrations(filepath.Join(t.TempDir(), "missing"))
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 0)
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "1_a.json"), []byte(`[]`), 0o644)
+	os.WriteFile(filepath.Join(dir, "01_b.json"), []byte(`[]`), 0o644)
+	_, err = LoadMigrations(dir)
+	assert.ErrorIs(t, err, ErrDuplicateMigration)
+
+	dir = t.TempDir()
+	os.WriteFile(filepath.Join(dir, "1_a.json"), []byte(`{`), 0o644)
+	_, err = LoadMigrations(dir)
+	assert.Error(t, err)
+}
+
+func TestMigratorAppliesOnce(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	calls := 0
+	migrations := []Migration{
+		{Version: 2, Description: "second", Up: func(ctx context.Context, database *mongo.Database) error {
+			calls++
+			return nil
+		}},
+		{Version: 1, Description: "first", Up: func(ctx context.Context, database *mongo.Database) error {
+			calls++
+			return nil
+		}},
+	}
+	migrator := NewMigrator(database, uuid.New().String(), migrations)
+
+	applied, err := migrator.Migrate(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, applied)
+
+	applied, err = migrator.Migrate(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, applied, 0)
+	assert.Equal(t, 2, calls)
+
+	pending, err := migrator.Pending(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, pending, 0)
+}
+
+func TestMigratorLocked(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	scope := uuid.New().String()
+	_, err := database.Collection("migrations").InsertOne(context.Background(), bson.D{
+		{"_id", "lock:" + scope},
+		{"owner", "other"},
+		{"lockeduntil", time.Now().Add(time.Hour)},
+	})
+	assert.NoError(t, err)
+
+	migrator := NewMigrator(database, scope, []Migration{
+		{Version: 1, Description: "first", Up: func(ctx context.Context, database *mongo.Database) error { return nil }},
+	})
+	migrator.LockWait = time.Second
+	_, err = migrator.Migrate(context.Background())
+
+	assert.ErrorIs(t, err, ErrMigrationLocked)
+}
+
+func TestMigratorNothingPendingSkipsLock(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	scope := uuid.New().String()
+	_, err := database.Collection("migrations").InsertOne(context.Background(), bson.D{
+		{"_id", "lock:" + scope},
+		{"owner", "other"},
+		{"lockeduntil", time.Now().Add(time.Hour)},
+	})
+	assert.NoError(t, err)
+
+	migrator := NewMigrator(database, scope, []Migration{})
+	migrator.LockWait = time.Minute
+	start := time.Now()
+	applied, err := migrator.Migrate(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, applied, 0)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestMigratorExtendsLock(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	scope := uuid.New().String()
+	var lockedUntil []time.Time
+	readLock := func(ctx context.Context, database *mongo.Database) error {
+		var lock struct {
+			LockedUntil time.Time `bson:"lockeduntil"`
+		}
+		err := database.Collection("migrations").FindOne(ctx, bson.D{{"_id", "lock:" + scope}}).Decode(&lock)
+		lockedUntil = append(lockedUntil, lock.LockedUntil)
+		return err
+	}
+	migrator := NewMigrator(database, scope, []Migration{
+		{Version: 1, Description: "slow", Up: func(ctx context.Context, database *mongo.Database) error {
+			if err := readLock(ctx, database); err != nil {
+				return err
+			}
+			time.Sleep(500 * time.Millisecond)
+			return readLock(ctx, database)
+		}},
+	})
+	migrator.LockTTL = 300 * time.Millisecond
+
+	_, err := migrator.Migrate(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, lockedUntil, 2)
+	assert.True(t, lockedUntil[1].After(lockedUntil[0]))
+	assert.True(t, lockedUntil[1].After(time.Now()))
+}
+
+func TestMigratorRenameField(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	collection := "rename_" + uuid.New().String()
+	_, err := database.Collection(collection).InsertOne(context.Background(), bson.D{{"grade", "a"}})
+	assert.NoError(t, err)
+
+	migrator := NewMigrator(database, collection, []Migration{
+		{Version: 1, Description: "rename grade", Up: RenameField(collection, "grade", "mark")},
+	})
+	_, err = migrator.Migrate(context.Background())
+	assert.NoError(t, err)
+
+	count, err := database.Collection(collection).CountDocuments(context.Background(), bson.D{{"mark", "a"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestEventStoreMigratorTenants(t *testing.T) {
+	for _, mode := range []TenantMode{TenantCollection, TenantDatabase} {
+		client, database := connectTestMongo(t)
+		t.Cleanup(func() { client.Disconnect(context.TODO()) })
+
+		options := MongoRepositoryOptions{
+			EventsCollection:    "events",
+			SnapshotsCollection: "snapshots",
+			Tenancy:             mode,
+			SkipMigrations:      true,
+		}
+		_, err := EventStoreMigrator(database, options, "")
+		assert.ErrorIs(t, err, ErrMissingTenant)
+
+		tenantID := uuid.New().String()
+		dropTestTenant(t, database, mode, tenantID)
+		migrator, err := EventStoreMigrator(database, options, tenantID)
+		assert.NoError(t, err)
+		applied, err := migrator.Migrate(context.Background())
+		assert.NoError(t, err)
+		assert.NotEmpty(t, applied)
+
+		// The repository finds the collections of the tenant already migrated
+		publisher := NewEventPublisher()
+		options.SkipMigrations = false
+		repo, err := NewMongoRepositoryWithOptions[*Student](database, &publisher, options)
+		assert.NoError(t, err)
+		tenantDatabase, prefix, err := repo.options.tenantNamespace(database, tenantID)
+		assert.NoError(t, err)
+		pending, err := repo.options.migrator(tenantDatabase, prefix).Pending(context.Background())
+		assert.NoError(t, err)
+		assert.Empty(t, pending)
+	}
+}
@@ -0,0 +1,276 @@
+package goddd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrNoArchiveStore = errors.New("no archive store configured")
+
+// MongoArchiveStore is an ArchiveStore keeping each compressed stream in a document of a collection
+type MongoArchiveStore struct {
+	collection *mongo.Collection
+}
+
+type archiveRecord struct {
+	Key        string    `bson:"_id"`
+	Events     []byte    `bson:"events"`
+	ArchivedAt time.Time `bson:"archivedat"`
+}
+
+func NewMongoArchiveStore(database *mongo.Database, collectionName string) *MongoArchiveStore {
+	return &MongoArchiveStore{
+		collection: database.Collection(collectionName),
+	}
+}
+
+func (s *MongoArchiveStore) Put(ctx context.Context, key string, events []Event) error {
+	archive, err := encodeArchive(events)
+	if err != nil {
+		return err
+	}
+	_, err = s.collection.ReplaceOne(
+		ctx,
+		bson.D{{Key: "_id", Value: key}},
+		archiveRecord{Key: key, Events: archive, ArchivedAt: time.Now()},
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+func (s *MongoArchiveStore) Get(ctx context.Context, key string) ([]Event, error) {
+	result := s.collection.FindOne(ctx, bson.D{{Key: "_id", Value: key}})
+	if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+		return nil, ErrNotArchived
+	} else if result.Err() != nil {
+		return nil, result.Err()
+	}
+
+	archive := archiveRecord{}
+	if err := result.Decode(&archive); err != nil {
+		return nil, err
+	}
+	return decodeArchive(archive.Events)
+}
+
+func (s *MongoArchiveStore) Delete(ctx context.Context, key string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: key}})
+	return err
+}
+
+// archiveKey is the archive key of a stream, distinct between tenants
+func archiveKey(collections mongoCollections, objectID string) string {
+	return collections.events.Database().Name() + "." + collections.events.Name() + ":" + objectID
+}
+
+// ArchiveIdleStreams moves at most limit streams without events for idleFor to the archive store and returns their IDs.
+// A limit of zero or less archives every idle stream.
+// Each stream is replaced by a stub holding its last version, so that Load rehydrates it and versions stay unique.
+// The archived events are left out of EventsSince, IterateEventsSince and QueryEvents until the stream is rehydrated.
+func (r *MongoRepository[T]) ArchiveIdleStreams(ctx context.Context, idleFor time.Duration, limit int) ([]string, error) {
+	if r.options.Archive == nil {
+		return nil, ErrNoArchiveStore
+	}
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Removed streams end with their removal and archived streams without new events end with their stub
+	pipeline := mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "objectid", Value: 1}, {Key: "version", Value: 1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$objectid"},
+			{Key: "lasttimestamp", Value: bson.D{{Key: "$max", Value: "$timestamp"}}},
+			{Key: "lastname", Value: bson.D{{Key: "$last", Value: "$name"}}},
+		}}},
+		{{Key: "$match", Value: bson.D{
+			{Key: "lasttimestamp", Value: bson.D{{Key: "$lt", Value: time.Now().Add(-idleFor).UnixNano()}}},
+			{Key: "lastname", Value: bson.D{{Key: "$nin", Value: bson.A{REMOVED_EVENT_NAME, ARCHIVED_EVENT_NAME}}}},
+		}}},
+	}
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	}
+	cursor, err := collections.events.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	idle := make([]struct {
+		ObjectID string `bson:"_id"`
+	}, 0)
+	if err := cursor.All(ctx, &idle); err != nil {
+		return nil, err
+	}
+
+	archived := make([]string, 0, len(idle))
+	for _, stream := range idle {
+		if err := r.archiveStream(ctx, collections, stream.ObjectID); err != nil {
+			return archived, err
+		}
+		archived = append(archived, stream.ObjectID)
+	}
+	return archived, nil
+}
+
+// archiveStream stores the stream in the archive, merged with its previous archive, then replaces its last event
+// with a stub before deleting the others. A stream interrupted in between still ends with its stub.
+func (r *MongoRepository[T]) archiveStream(ctx context.Context, collections mongoCollections, objectID string) error {
+	events, err := r.ObjectEventsSinceVersion(ctx, objectID, -1)
+	if err != nil || len(events) == 0 {
+		return err
+	}
+	key := archiveKey(collections, objectID)
+	events, err = r.withArchivedEvents(ctx, key, events)
+	if err != nil {
+		return err
+	}
+	if err := r.options.Archive.Put(ctx, key, events); err != nil {
+		return err
+	}
+
+	last := events[len(events)-1]
+	stub := ReloadEvent(uuid.NewString(), objectID, ARCHIVED_EVENT_NAME, last.Version(), []byte{}, last.Timestamp())
+	_, err = collections.events.ReplaceOne(
+		ctx,
+		bson.D{{Key: "objectid", Value: objectID}, {Key: "version", Value: last.Version()}},
+		toRecords([]Event{stub})[0],
+	)
+	if err != nil {
+		return err
+	}
+	_, err = collections.events.DeleteMany(ctx, bson.D{
+		{Key: "objectid", Value: objectID},
+		{Key: "version", Value: bson.D{{Key: "$lt", Value: last.Version()}}},
+	})
+	return err
+}
+
+// withArchivedEvents merges the events of the event store with those already archived when the stream has a stub
+func (r *MongoRepository[T]) withArchivedEvents(ctx context.Context, key string, events []Event) ([]Event, error) {
+	byVersion := make(map[int]Event, len(events))
+	stubbed := false
+	for _, event := range events {
+		if event.Name() == ARCHIVED_EVENT_NAME {
+			stubbed = true
+			continue
+		}
+		byVersion[event.Version()] = event
+	}
+	if !stubbed {
+		return events, nil
+	}
+
+	archived, err := r.options.Archive.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	for _, event := range archived {
+		if _, ok := byVersion[event.Version()]; !ok {
+			byVersion[event.Version()] = event
+		}
+	}
+
+	merged := make([]Event, 0, len(byVersion))
+	for _, event := range byVersion {
+		merged = append(merged, event)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Version() < merged[j].Version()
+	})
+	return merged, nil
+}
+
+// rehydrate moves an archived stream back to the event store. The events are inserted before the stub is replaced
+// by the last of them, so that a stream interrupted in between is rehydrated again on next Load.
+func (r *MongoRepository[T]) rehydrate(ctx context.Context, objectID string) error {
+	if r.options.Archive == nil {
+		return fmt.Errorf("%w : cannot rehydrate %s", ErrNoArchiveStore, objectID)
+	}
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return err
+	}
+	key := archiveKey(collections, objectID)
+	stubFilter := bson.D{{Key: "objectid", Value: objectID}, {Key: "name", Value: ARCHIVED_EVENT_NAME}}
+
+	events, err := r.options.Archive.Get(ctx, key)
+	if errors.Is(err, ErrNotArchived) {
+		// Another instance may have rehydrated the stream meanwhile
+		count, err := collections.events.CountDocuments(ctx, stubFilter)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w : %s has a stub but no archive", ErrNotArchived, objectID)
+		}
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("%w : empty archive for %s", ErrNotArchived, objectID)
+	}
+
+	// The rehydrated events are marked so that the change streams skip them
+	records := toRecords(events)
+	for i, eventRecord := range records {
+		rehydrated := eventRecord.(record)
+		rehydrated.Rehydrated = true
+		records[i] = rehydrated
+	}
+	rehydrated := records[:len(records)-1]
+	if len(rehydrated) > 0 {
+		_, err = collections.events.InsertMany(ctx, rehydrated, options.InsertMany().SetOrdered(false))
+		if err != nil {
+			if err := alreadyRehydrated(ctx, collections, rehydrated, err); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = collections.events.ReplaceOne(ctx, stubFilter, records[len(records)-1])
+	if err != nil {
+		return err
+	}
+	return r.options.Archive.Delete(ctx, key)
+}
+
+// alreadyRehydrated returns insertErr unless it only reports events already in the event store, inserted by an
+// interrupted or concurrent rehydration. The events rejected by the version index are checked to be the same ones.
+func alreadyRehydrated(ctx context.Context, collections mongoCollections, records []interface{}, insertErr error) error {
+	duplicates, ok := duplicateKeyWrites(insertErr)
+	if !ok {
+		return insertErr
+	}
+	for index := range duplicates {
+		if index != eventIDIndex && index != versionIndex {
+			return insertErr
+		}
+	}
+	if len(duplicates[versionIndex]) == 0 {
+		return nil
+	}
+
+	eventIDs := make(bson.A, len(duplicates[versionIndex]))
+	for i, position := range duplicates[versionIndex] {
+		eventIDs[i] = records[position].(record).ID
+	}
+	count, err := collections.events.CountDocuments(ctx, bson.D{{Key: "id", Value: bson.D{{Key: "$in", Value: eventIDs}}}})
+	if err != nil {
+		return err
+	}
+	if count != int64(len(eventIDs)) {
+		return insertErr
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package goddd
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ResumeTokenStore persists the position of named change streams
+type ResumeTokenStore interface {
+	// Load returns the last saved token of the feed, nil if there is none
+	Load(ctx context.Context, feedName string) (bson.Raw, error)
+	Save(ctx context.Context, feedName string, token bson.Raw) error
+}
+
+// MongoResumeTokenStore is a ResumeTokenStore keeping one document per feed
+type MongoResumeTokenStore struct {
+	collection *mongo.Collection
+}
+
+type resumeTokenRecord struct {
+	Name      string    `bson:"_id"`
+	Token     bson.Raw  `bson:"token"`
+	UpdatedAt time.Time `bson:"updatedat"`
+}
+
+func NewMongoResumeTokenStore(database *mongo.Database, collectionName string) *MongoResumeTokenStore {
+	return &MongoResumeTokenStore{
+		collection: database.Collection(collectionName),
+	}
+}
+
+func (s *MongoResumeTokenStore) Load(ctx context.Context, feedName string) (bson.Raw, error) {
+	result := s.collection.FindOne(ctx, bson.D{{Key: "_id", Value: feedName}})
+	if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+		return nil, nil
+	} else if result.Err() != nil {
+		return nil, result.Err()
+	}
+
+	tokenRecord := resumeTokenRecord{}
+	if err := result.Decode(&tokenRecord); err != nil {
+		return nil, err
+	}
+	return tokenRecord.Token, nil
+}
+
+func (s *MongoResumeTokenStore) Save(ctx context.Context, feedName string, token bson.Raw) error {
+	_, err := s.collection.ReplaceOne(
+		ctx,
+		bson.D{{Key: "_id", Value: feedName}},
+		resumeTokenRecord{Name: feedName, Token: token, UpdatedAt: time.Now()},
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
+
+// MongoEventFeed hands the events inserted into a Mongo event store, by any instance, to its handlers.
+// It relies on change streams, which require a replica set.
+type MongoEventFeed struct {
+	collection    func(ctx context.Context) (*mongo.Collection, error)
+	name          string
+	tokens        ResumeTokenStore
+	errChan       chan<- error
+	mutex         sync.RWMutex
+	subscriptions []*listenerSubscription
+
+	// Backoff is the delay before watching again after consecutive change stream errors,
+	// exponential up to 30 seconds if nil
+	Backoff Backoff
+}
+
+type changeEvent struct {
+	FullDocument record `bson:"fullDocument"`
+}
+
+// Feed creates a feed of the events saved in the repository.
+// Its position is saved in tokens under name after every dispatched event so that it resumes where it stopped.
+// Without tokens, or on first start, the feed starts with the events inserted once it runs.
+// With a tenant aware repository, the feed watches the tenant of the context given to Run.
+// Events moved back from the archive when a stream is rehydrated are not dispatched again.
+func (r *MongoRepository[T]) Feed(name string, tokens ResumeTokenStore, errChan chan<- error) *MongoEventFeed {
+	return &MongoEventFeed{
+		collection: func(ctx context.Context) (*mongo.Collection, error) {
+			collections, err := r.collections(ctx)
+			return collections.events, err
+		},
+		name:          name,
+		tokens:        tokens,
+		errChan:       errChan,
+		subscriptions: make([]*listenerSubscription, 0),
+		Backoff:       defaultListenerBackoff,
+	}
+}
+
+// Register subscribes receiver to the events matching all the filters
+func (f *MongoEventFeed) Register(receiver EventReceiver, filters ...EventFilter) *Subscription {
+	return f.RegisterHandler(receiverHandler{receiver: receiver}, filters...)
+}
+
+// RegisterHandler subscribes handler to the events matching all the filters
+func (f *MongoEventFeed) RegisterHandler(handler EventHandler, filters ...EventFilter) *Subscription {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	sub := &listenerSubscription{
+		handler: handler,
+		filters: filters,
+	}
+	f.subscriptions = append(f.subscriptions, sub)
+
+	return &Subscription{
+		unsubscribe: func() { f.unregister(sub) },
+	}
+}
+
+func (f *MongoEventFeed) unregister(sub *listenerSubscription) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	subscriptions := make([]*listenerSubscription, 0, len(f.subscriptions))
+	for _, other := range f.subscriptions {
+		if other != sub {
+			subscriptions = append(subscriptions, other)
+		}
+	}
+	f.subscriptions = subscriptions
+}
+
+// Run watches the event store until ctx is done, then returns ctx error.
+// The change stream is reopened from the last saved token when it fails.
+func (f *MongoEventFeed) Run(ctx context.Context) error {
+	backoff := f.Backoff
+	if backoff == nil {
+		backoff = defaultListenerBackoff
+	}
+
+	failures := 0
+	for ctx.Err() == nil {
+		watched, err := f.watch(ctx)
+		if ctx.Err() != nil {
+			break
+		}
+		if watched {
+			failures = 0
+		}
+		failures++
+		f.report(ctx, err)
+		if sleep(ctx, backoff(failures)) != nil {
+			break
+		}
+	}
+
+	return ctx.Err()
+}
+
+// watch dispatches the inserted events until the change stream fails.
+// Changes that cannot be decoded are reported and skipped.
+// It tells whether at least one event was dispatched.
+func (f *MongoEventFeed) watch(ctx context.Context) (bool, error) {
+	opts := options.ChangeStream()
+	if f.tokens != nil {
+		token, err := f.tokens.Load(ctx, f.name)
+		if err != nil {
+			return false, err
+		}
+		if token != nil {
+			opts.SetResumeAfter(token)
+		}
+	}
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.D{
+			{Key: "operationType", Value: "insert"},
+			{Key: "fullDocument.rehydrated", Value: bson.D{{Key: "$ne", Value: true}}},
+		}}},
+	}
+	collection, err := f.collection(ctx)
+	if err != nil {
+		return false, err
+	}
+	stream, err := collection.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return false, err
+	}
+	defer stream.Close(context.Background())
+
+	dispatched := false
+	for stream.Next(ctx) {
+		change := changeEvent{}
+		if err := stream.Decode(&change); err != nil {
+			f.report(ctx, err)
+		} else {
+			f.dispatch(ctx, fromRecords([]record{change.FullDocument})[0])
+			dispatched = true
+		}
+
+		if f.tokens != nil {
+			if err := f.tokens.Save(ctx, f.name, stream.ResumeToken()); err != nil {
+				f.report(ctx, err)
+			}
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return dispatched, err
+	}
+	return dispatched, errors.New("change stream closed")
+}
+
+func (f *MongoEventFeed) dispatch(ctx context.Context, event Event) {
+	f.mutex.RLock()
+	subscriptions := f.subscriptions
+	f.mutex.RUnlock()
+
+	for _, sub := range subscriptions {
+		if !matchesAll(sub.filters, event) {
+			continue
+		}
+		if err := safeHandle(ctx, sub.handler, event); err != nil {
+			f.report(ctx, err)
+		}
+	}
+}
+
+// report sends err to the error channel, if any, unless ctx is done
+func (f *MongoEventFeed) report(ctx context.Context, err error) {
+	if f.errChan == nil {
+		return
+	}
+	select {
+	case f.errChan <- err:
+	case <-ctx.Done():
+	}
+}
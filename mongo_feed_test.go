@@ -0,0 +1,102 @@
+package goddd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func receiveObjectEvent(t *testing.T, received <-chan Event, objectID string) {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-received:
+			if event.ObjectId() == objectID {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no event received for %s", objectID)
+		}
+	}
+}
+
+func TestMongoFeed(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	publisher := NewEventPublisher()
+	repo, err := NewMongoRepository[*Student](database, &publisher)
+	assert.NoError(t, err)
+	tokens := NewMongoResumeTokenStore(database, "feed_tokens")
+	feedName := uuid.New().String()
+	errChan := make(chan error, 10)
+	received := make(chan Event, 10)
+
+	feed := repo.Feed(feedName, tokens, errChan)
+	feed.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		received <- event
+		return nil
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	go feed.Run(ctx)
+	// HACK: wait for the change stream to be opened
+	time.Sleep(500 * time.Millisecond)
+
+	object := Student{ID: uuid.New().String()}
+	object.SetGrade("a")
+	assert.NoError(t, repo.Save(context.Background(), &object))
+	receiveObjectEvent(t, received, object.ID)
+	cancel()
+
+	token, err := tokens.Load(context.Background(), feedName)
+	assert.NoError(t, err)
+	assert.NotNil(t, token)
+
+	// Events saved while no feed runs are received once it resumes
+	missed := Student{ID: uuid.New().String()}
+	missed.SetGrade("b")
+	assert.NoError(t, repo.Save(context.Background(), &missed))
+
+	feed = repo.Feed(feedName, tokens, errChan)
+	feed.RegisterHandler(ReceiverFunc(func(ctx context.Context, event Event) error {
+		received <- event
+		return nil
+	}))
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go feed.Run(ctx)
+
+	receiveObjectEvent(t, received, missed.ID)
+	assert.Len(t, errChan, 0)
+}
+
+func TestMongoFeedDefaults(t *testing.T) {
+	failures := make(chan struct{}, 10)
+	feed := &MongoEventFeed{
+		collection: func(ctx context.Context) (*mongo.Collection, error) {
+			failures <- struct{}{}
+			return nil, errors.New("unavailable")
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- feed.Run(ctx)
+	}()
+	<-failures
+	<-failures
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("feed did not stop")
+	}
+}
@@ -0,0 +1,55 @@
+package goddd
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type inboxRecord struct {
+	ID          string    `bson:"_id"`
+	ProcessedAt time.Time `bson:"processedat"`
+}
+
+// MongoInbox is an Inbox storing processed event IDs in a collection
+type MongoInbox struct {
+	collection *mongo.Collection
+}
+
+// NewMongoInbox creates an inbox on the given collection.
+// When ttl is positive, a TTL index makes Mongo forget the processed events after it.
+func NewMongoInbox(ctx context.Context, database *mongo.Database, collectionName string, ttl time.Duration) (*MongoInbox, error) {
+	collection := database.Collection(collectionName)
+	if ttl > 0 {
+		_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "processedat", Value: 1}},
+			Options: options.Index().SetName("processedat_ttl").SetExpireAfterSeconds(int32(ttl.Seconds())),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &MongoInbox{collection: collection}, nil
+}
+
+func (i *MongoInbox) Processed(ctx context.Context, eventID string) (bool, error) {
+	count, err := i.collection.CountDocuments(ctx, bson.D{{Key: "_id", Value: eventID}}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (i *MongoInbox) MarkProcessed(ctx context.Context, eventID string) error {
+	_, err := i.collection.ReplaceOne(
+		ctx,
+		bson.D{{Key: "_id", Value: eventID}},
+		inboxRecord{ID: eventID, ProcessedAt: time.Now()},
+		options.Replace().SetUpsert(true),
+	)
+	return err
+}
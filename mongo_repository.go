@@ -4,18 +4,29 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/dgraph-io/ristretto"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type snapshot struct {
+// Snapshot is the memento of an object at a given version
+type Snapshot struct {
 	ObjectID string
-	Version  int
-	Payload  []byte
+	// Version is the number of events applied to the memento, the LastVersion of the object
+	Version int
+	Payload []byte
+}
+
+// lastEventVersion returns the version of the last event applied to the memento, events starting at version 0
+func (s Snapshot) lastEventVersion() int {
+	return s.Version - 1
 }
 
 type record struct {
@@ -25,52 +36,317 @@ type record struct {
 	Timestamp int64
 	Name      string
 	Payload   []byte
+	// Rehydrated marks the events moved back from the archive, which the change streams skip
+	Rehydrated bool `bson:"rehydrated,omitempty"`
 }
 
 type MongoRepository[T DomainObject] struct {
+	database            *mongo.Database
+	options             MongoRepositoryOptions
 	collection          *mongo.Collection
 	snapshotsCollection *mongo.Collection
 	publisher           *EventPublisher
-	snapshotsCache      *ristretto.Cache
+	snapshotsCache      SnapshotCache
+	migratedTenants     sync.Map
+}
+
+// TenantMode tells how a MongoRepository isolates tenants
+type TenantMode int
+
+const (
+	// TenantNone stores every object in the same collections
+	TenantNone TenantMode = iota
+	// TenantCollection prefixes the collection names with the tenant ID
+	TenantCollection
+	// TenantDatabase stores each tenant in its own database
+	TenantDatabase
+)
+
+var ErrMissingTenant = errors.New("missing tenant in context")
+var ErrInvalidTenant = errors.New("invalid tenant")
+
+// MongoRepositoryOptions configures the collections used by a MongoRepository
+type MongoRepositoryOptions struct {
+	// EventsCollection is the events collection name, "event_store" by default
+	EventsCollection string
+	// SnapshotsCollection is the snapshots collection name, "domain_event_snapshots" by default
+	SnapshotsCollection string
+	// CollectionPrefix is prepended to both collection names
+	CollectionPrefix string
+	// Tenancy routes every operation according to the tenant set in its context with WithTenant
+	Tenancy TenantMode
+	// TenantDatabasePrefix is prepended to the tenant ID to name its database in TenantDatabase mode.
+	// It defaults to the repository database name followed by an underscore.
+	TenantDatabasePrefix string
+	// SkipMigrations leaves applying the event store migrations to EventStoreMigrator or the goddd-migrate command
+	SkipMigrations bool
+	// Archive stores the streams moved out of the events collection by ArchiveIdleStreams.
+	// Load and LoadMany rehydrate archived streams, but EventsSince, IterateEventsSince and QueryEvents
+	// only read the events collection: the events of archived streams are missing from them until rehydrated.
+	Archive ArchiveStore
+	// SnapshotCache caches the snapshots, an in-process RistrettoSnapshotCache by default.
+	// Use NoSnapshotCache to disable caching.
+	SnapshotCache SnapshotCache
+}
+
+type mongoCollections struct {
+	events    *mongo.Collection
+	snapshots *mongo.Collection
+}
+
+type tenantContextKey struct{}
+
+// WithTenant returns a context routing the MongoRepository operations to the given tenant
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant set with WithTenant
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantContextKey{}).(string)
+	return tenantID, ok && tenantID != ""
 }
 
 func NewMongoRepository[T DomainObject](database *mongo.Database, publisher *EventPublisher) (*MongoRepository[T], error) {
-	cache, err := ristretto.NewCache(&ristretto.Config{
-		NumCounters: 1e3,
-		MaxCost:     1 << 30,
-		BufferItems: 64,
-	})
+	return NewMongoRepositoryWithOptions[T](database, publisher, MongoRepositoryOptions{})
+}
+
+func NewMongoRepositoryWithOptions[T DomainObject](database *mongo.Database, publisher *EventPublisher, options MongoRepositoryOptions) (*MongoRepository[T], error) {
+	options = options.withDefaults(database)
+
+	if options.SnapshotCache == nil {
+		cache, err := NewRistrettoSnapshotCache(RistrettoSnapshotCacheOptions{})
+		if err != nil {
+			return nil, err
+		}
+		options.SnapshotCache = cache
+	}
+
+	repository := &MongoRepository[T]{
+		database:            database,
+		options:             options,
+		collection:          database.Collection(options.CollectionPrefix + options.EventsCollection),
+		snapshotsCollection: database.Collection(options.CollectionPrefix + options.SnapshotsCollection),
+		publisher:           publisher,
+		snapshotsCache:      options.SnapshotCache,
+	}
+	if options.Tenancy == TenantNone {
+		err := repository.migrate(context.Background(), database, options.CollectionPrefix)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return repository, nil
+}
+
+// withDefaults fills the unset collection names and tenant database prefix
+func (o MongoRepositoryOptions) withDefaults(database *mongo.Database) MongoRepositoryOptions {
+	if o.EventsCollection == "" {
+		o.EventsCollection = "event_store"
+	}
+	if o.SnapshotsCollection == "" {
+		o.SnapshotsCollection = "domain_event_snapshots"
+	}
+	if o.TenantDatabasePrefix == "" {
+		o.TenantDatabasePrefix = database.Name() + "_"
+	}
+	return o
+}
+
+// tenantNamespace returns the database and the collection prefix of a tenant
+func (o MongoRepositoryOptions) tenantNamespace(database *mongo.Database, tenantID string) (*mongo.Database, string, error) {
+	if o.Tenancy == TenantNone {
+		return database, o.CollectionPrefix, nil
+	}
+	if tenantID == "" {
+		return nil, "", ErrMissingTenant
+	}
+	if strings.ContainsAny(tenantID, "./\\ \"$*<>:|?\x00") {
+		return nil, "", fmt.Errorf("%w : %q", ErrInvalidTenant, tenantID)
+	}
+
+	if o.Tenancy == TenantDatabase {
+		return database.Client().Database(o.TenantDatabasePrefix + tenantID), o.CollectionPrefix, nil
+	}
+	return database, tenantID + "_" + o.CollectionPrefix, nil
+}
+
+// EventStoreMigrator returns the migrator of the event store collections a MongoRepository created with the
+// same database and options uses for tenantID, which is ignored without tenancy
+func EventStoreMigrator(database *mongo.Database, options MongoRepositoryOptions, tenantID string) (*Migrator, error) {
+	options = options.withDefaults(database)
+	tenantDatabase, prefix, err := options.tenantNamespace(database, tenantID)
 	if err != nil {
 		return nil, err
 	}
-	err = MigrateMongoDB(database, "./migrations")
+	return options.migrator(tenantDatabase, prefix), nil
+}
+
+// migrator returns the migrator of the event store collections with the given prefix.
+// Migrations are recorded in the scope of the events collection so that each set of collections evolves independently.
+func (o MongoRepositoryOptions) migrator(database *mongo.Database, prefix string) *Migrator {
+	eventsCollection := prefix + o.EventsCollection
+	migrations := EventStoreMigrations(eventsCollection, prefix+o.SnapshotsCollection)
+	return NewMigrator(database, eventsCollection, migrations)
+}
+
+// collections returns the collections of the tenant set in ctx, migrating them on first use
+func (r *MongoRepository[T]) collections(ctx context.Context) (mongoCollections, error) {
+	if r.options.Tenancy == TenantNone {
+		return mongoCollections{events: r.collection, snapshots: r.snapshotsCollection}, nil
+	}
+
+	tenantID, _ := TenantFromContext(ctx)
+	database, prefix, err := r.options.tenantNamespace(r.database, tenantID)
 	if err != nil {
-		return nil, err
+		return mongoCollections{}, err
 	}
-	return &MongoRepository[T]{
-		collection:          database.Collection("event_store"),
-		snapshotsCollection: database.Collection("domain_event_snapshots"),
-		publisher:           publisher,
-		snapshotsCache:      cache,
-	}, nil
+	collections := mongoCollections{
+		events:    database.Collection(prefix + r.options.EventsCollection),
+		snapshots: database.Collection(prefix + r.options.SnapshotsCollection),
+	}
+
+	namespace := database.Name() + "." + collections.events.Name()
+	if _, migrated := r.migratedTenants.Load(namespace); !migrated {
+		if err := r.migrate(ctx, database, prefix); err != nil {
+			return mongoCollections{}, err
+		}
+		r.migratedTenants.Store(namespace, true)
+	}
+	return collections, nil
+}
+
+// migrate applies the event store migrations to the collections with the given prefix
+func (r *MongoRepository[T]) migrate(ctx context.Context, database *mongo.Database, prefix string) error {
+	if r.options.SkipMigrations {
+		return nil
+	}
+
+	_, err := r.options.migrator(database, prefix).Migrate(ctx)
+	return err
+}
+
+// snapshotKey is the cache key of an object snapshot, distinct between tenants
+func snapshotKey(collections mongoCollections, objectID string) string {
+	return collections.snapshots.Database().Name() + "." + collections.snapshots.Name() + ":" + objectID
 }
 
 func (r *MongoRepository[T]) Save(ctx context.Context, object T) error {
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return err
+	}
 	events := object.CollectUnsavedEvents()
 
 	records := toRecords(events)
-	_, err := r.collection.InsertMany(ctx, records)
+	_, err = collections.events.InsertMany(ctx, records)
 	if err != nil && !errors.Is(err, mongo.ErrEmptySlice) {
-		if mongo.IsDuplicateKeyError(err) {
-			return ConcurrencyError
+		if duplicates, ok := duplicateKeyWrites(err); ok && len(duplicates) == 1 && len(duplicates[versionIndex]) > 0 {
+			return r.versionConflict(ctx, object.ObjectID(), events[0].Version())
 		}
 		return err
 	}
 
-	r.publisher.Publish(events)
+	r.publisher.PublishContext(ctx, events)
 	return r.saveSnapshot(ctx, object)
 }
 
+// SaveExpecting checks the version of the stream before saving. The unique index on the object ID and version
+// still detects the objects saved concurrently between the check and the insertion.
+func (r *MongoRepository[T]) SaveExpecting(ctx context.Context, object T, expectedVersion int) error {
+	if expectedVersion != Any {
+		exists, err := r.Exists(ctx, object.ObjectID())
+		if err != nil {
+			return err
+		}
+		actualVersion, err := r.streamVersion(ctx, object.ObjectID())
+		if err != nil {
+			return err
+		}
+		if err := checkExpectedVersion(object.ObjectID(), expectedVersion, actualVersion, exists); err != nil {
+			return err
+		}
+	}
+
+	return r.Save(ctx, object)
+}
+
+// streamVersion returns the number of versions of a stream, 0 if it has no events
+func (r *MongoRepository[T]) streamVersion(ctx context.Context, objectID string) (int, error) {
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return 0, err
+	}
+	opts := options.FindOne().SetSort(bson.D{{"version", -1}}).SetProjection(bson.D{{"version", 1}})
+	result := collections.events.FindOne(ctx, bson.D{{"objectid", objectID}}, opts)
+	if errors.Is(result.Err(), mongo.ErrNoDocuments) {
+		return 0, nil
+	} else if result.Err() != nil {
+		return 0, result.Err()
+	}
+
+	last := record{}
+	if err := result.Decode(&last); err != nil {
+		return 0, err
+	}
+	return last.Version + 1, nil
+}
+
+// Unique indexes of the events collection
+const (
+	versionIndex = "objectID_version_unique"
+	eventIDIndex = "eventid_unique"
+)
+
+var duplicateKeyIndex = regexp.MustCompile(`index: (\S+) dup key`)
+
+// duplicateKeyWrites returns the positions of the writes rejected by err, grouped by the unique index they duplicate.
+// It returns false when err reports other failures.
+func duplicateKeyWrites(err error) (map[string][]int, bool) {
+	var writeErrors []mongo.WriteError
+	var bulkErr mongo.BulkWriteException
+	var writeErr mongo.WriteException
+	switch {
+	case errors.As(err, &bulkErr):
+		if bulkErr.WriteConcernError != nil {
+			return nil, false
+		}
+		for _, bulkWriteErr := range bulkErr.WriteErrors {
+			writeErrors = append(writeErrors, bulkWriteErr.WriteError)
+		}
+	case errors.As(err, &writeErr):
+		if writeErr.WriteConcernError != nil {
+			return nil, false
+		}
+		writeErrors = writeErr.WriteErrors
+	default:
+		return nil, false
+	}
+
+	duplicates := make(map[string][]int)
+	for _, writeErr := range writeErrors {
+		match := duplicateKeyIndex.FindStringSubmatch(writeErr.Message)
+		if writeErr.Code != 11000 || match == nil {
+			return nil, false
+		}
+		duplicates[match[1]] = append(duplicates[match[1]], writeErr.Index)
+	}
+	return duplicates, len(duplicates) > 0
+}
+
+// versionConflict reports a stream saved concurrently while expected at the given version
+func (r *MongoRepository[T]) versionConflict(ctx context.Context, objectID string, expectedVersion int) error {
+	actualVersion, err := r.streamVersion(ctx, objectID)
+	if err != nil {
+		return ConcurrencyError
+	}
+	return &VersionConflictError{
+		ObjectID: objectID,
+		Expected: expectedVersion,
+		Actual:   actualVersion,
+	}
+}
+
 func (r *MongoRepository[T]) Update(ctx context.Context, objectID string, object T, nbRetries int, updater func(T) (T, error)) (T, error) {
 	return repoUpdate[T](ctx, r, objectID, object, nbRetries, updater)
 }
@@ -99,6 +375,10 @@ func (r *MongoRepository[T]) saveSnapshot(ctx context.Context, object T) error {
 }
 
 func (r *MongoRepository[T]) persistSnapshot(ctx context.Context, object T, mementizer DomainObjectMemento) error {
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return err
+	}
 	memento, err := mementizer.DumpMemento()
 	if err != nil {
 		return err
@@ -108,7 +388,7 @@ func (r *MongoRepository[T]) persistSnapshot(ctx context.Context, object T, meme
 		return err
 	}
 
-	snap := snapshot{
+	snap := Snapshot{
 		ObjectID: object.ObjectID(),
 		Version:  object.LastVersion(),
 		Payload:  bytePayload,
@@ -122,13 +402,13 @@ func (r *MongoRepository[T]) persistSnapshot(ctx context.Context, object T, meme
 		"objectid": object.ObjectID(),
 	}
 
-	_, err = r.snapshotsCollection.UpdateOne(ctx, filter, update, options)
-
-	if r.snapshotsCache != nil {
-		r.snapshotsCache.Set(object.ObjectID(), snap, 1)
+	_, err = collections.snapshots.UpdateOne(ctx, filter, update, options)
+	if err != nil {
+		return err
 	}
 
-	return err
+	r.snapshotsCache.Set(ctx, snapshotKey(collections, object.ObjectID()), snap)
+	return nil
 }
 
 func (r *MongoRepository[T]) Load(ctx context.Context, objectID string, object T) error {
@@ -147,45 +427,225 @@ func (r *MongoRepository[T]) Load(ctx context.Context, objectID string, object T
 		return err
 	}
 
-	var objectEvents []Event
+	sinceVersion := -1
 	if snapshot != nil {
 		err = r.reloadSnapshot(snapshot, object)
 		if err != nil {
 			return err
 		}
-		objectEvents, err = r.ObjectEventsSinceVersion(ctx, objectID, snapshot.Version)
-	} else {
-		objectEvents, err = r.objectRepositoryEvents(ctx, objectID)
+		sinceVersion = snapshot.lastEventVersion()
 	}
 
+	events, err := r.IterateObjectEvents(ctx, objectID, sinceVersion, 0)
 	if err != nil {
 		return err
 	}
+	defer events.Close(context.Background())
 
-	for _, event := range objectEvents {
-		err = object.LoadEvent(object, event)
+	for events.Next(ctx) {
+		if events.Event().Name() == ARCHIVED_EVENT_NAME {
+			if err := r.rehydrate(ctx, objectID); err != nil {
+				return err
+			}
+			return r.Load(ctx, objectID, object)
+		}
+		err = object.LoadEvent(object, events.Event())
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return events.Err()
 }
 
-func (r *MongoRepository[T]) reloadSnapshot(snapshot *snapshot, object T) error {
+// LoadMany fetches the snapshots and events of all the objects with one query each, then rebuilds them concurrently
+func (r *MongoRepository[T]) LoadMany(ctx context.Context, objectIDs []string, factory func() T) (map[string]T, error) {
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	objectIDs = uniqueIDs(objectIDs)
+	if len(objectIDs) == 0 {
+		return map[string]T{}, nil
+	}
+
+	snapshots, err := r.snapshotsOf(ctx, collections, objectIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := bson.A{}
+	withoutSnapshot := make([]string, 0)
+	for _, objectID := range objectIDs {
+		if snap, ok := snapshots[objectID]; ok {
+			filters = append(filters, bson.D{{"objectid", objectID}, {"version", bson.D{{"$gt", snap.lastEventVersion()}}}})
+		} else {
+			withoutSnapshot = append(withoutSnapshot, objectID)
+		}
+	}
+	if len(withoutSnapshot) > 0 {
+		filters = append(filters, bson.D{{"objectid", bson.D{{"$in", withoutSnapshot}}}})
+	}
+
+	opts := options.Find().SetSort(bson.D{{"objectid", 1}, {"version", 1}})
+	cursor, err := collections.events.Find(ctx, bson.D{{"$or", filters}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	records := make([]record, 0)
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	streams := make(map[string][]Event, len(objectIDs))
+	removed := make(map[string]bool)
+	archived := make(map[string]bool)
+	for _, event := range fromRecords(records) {
+		if event.Name() == REMOVED_EVENT_NAME {
+			removed[event.ObjectId()] = true
+		}
+		if event.Name() == ARCHIVED_EVENT_NAME {
+			archived[event.ObjectId()] = true
+		}
+		streams[event.ObjectId()] = append(streams[event.ObjectId()], event)
+	}
+	for objectID := range archived {
+		if err := r.rehydrate(ctx, objectID); err != nil {
+			return nil, err
+		}
+		sinceVersion := -1
+		if snap, ok := snapshots[objectID]; ok {
+			sinceVersion = snap.lastEventVersion()
+		}
+		streams[objectID], err = r.ObjectEventsSinceVersion(ctx, objectID, sinceVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	missing := make([]string, 0)
+	found := make([]string, 0, len(objectIDs))
+	for _, objectID := range objectIDs {
+		_, hasSnapshot := snapshots[objectID]
+		_, hasEvents := streams[objectID]
+		if removed[objectID] || (!hasSnapshot && !hasEvents) {
+			missing = append(missing, objectID)
+		} else {
+			found = append(found, objectID)
+		}
+	}
+
+	objects, err := r.rebuild(found, snapshots, streams, factory)
+	if err != nil {
+		return objects, err
+	}
+	if len(missing) > 0 {
+		return objects, &MissingObjectsError{ObjectIDs: missing}
+	}
+	return objects, nil
+}
+
+// rebuild applies the snapshots and events to new objects, using one worker per CPU
+func (r *MongoRepository[T]) rebuild(objectIDs []string, snapshots map[string]Snapshot, streams map[string][]Event, factory func() T) (map[string]T, error) {
+	objects := make(map[string]T, len(objectIDs))
+	var mutex sync.Mutex
+	var firstErr error
+
+	ids := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for objectID := range ids {
+				object, err := r.rebuildObject(snapshots, streams[objectID], objectID, factory)
+
+				mutex.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("could not load %s : %w", objectID, err)
+				} else if err == nil {
+					objects[objectID] = object
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, objectID := range objectIDs {
+		ids <- objectID
+	}
+	close(ids)
+	wg.Wait()
+
+	return objects, firstErr
+}
+
+func (r *MongoRepository[T]) rebuildObject(snapshots map[string]Snapshot, events []Event, objectID string, factory func() T) (T, error) {
+	object := factory()
+	object.Clear()
+
+	if snap, ok := snapshots[objectID]; ok {
+		if err := r.reloadSnapshot(&snap, object); err != nil {
+			return object, err
+		}
+	}
+	for _, event := range events {
+		if err := object.LoadEvent(object, event); err != nil {
+			return object, err
+		}
+	}
+	return object, nil
+}
+
+// snapshotsOf returns the last snapshots of the objects having one, from the cache when possible
+func (r *MongoRepository[T]) snapshotsOf(ctx context.Context, collections mongoCollections, objectIDs []string) (map[string]Snapshot, error) {
+	snapshots := make(map[string]Snapshot, len(objectIDs))
+	uncached := make([]string, 0, len(objectIDs))
+	for _, objectID := range objectIDs {
+		if snap, ok := r.snapshotsCache.Get(ctx, snapshotKey(collections, objectID)); ok {
+			snapshots[objectID] = snap
+			continue
+		}
+		uncached = append(uncached, objectID)
+	}
+	if len(uncached) == 0 {
+		return snapshots, nil
+	}
+
+	cursor, err := collections.snapshots.Find(ctx, bson.D{{"objectid", bson.D{{"$in", uncached}}}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	found := make([]Snapshot, 0)
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	for _, snap := range found {
+		snapshots[snap.ObjectID] = snap
+		r.snapshotsCache.Fill(ctx, snapshotKey(collections, snap.ObjectID), snap)
+	}
+	return snapshots, nil
+}
+
+func (r *MongoRepository[T]) reloadSnapshot(snapshot *Snapshot, object T) error {
 	var objectInter interface{} = object
 	mementizer, isMemento := objectInter.(DomainObjectMemento)
 
 	if isMemento {
-		mementizer.SetVersion(snapshot.Version)
+		mementizer.SetVersion(snapshot.lastEventVersion())
 		return mementizer.ApplyMemento(snapshot.Payload)
 	}
 	return nil
 }
 
 func (r *MongoRepository[T]) Exists(ctx context.Context, objectId string) (bool, error) {
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return false, err
+	}
 	filter := bson.D{{"objectid", objectId}, {"name", bson.D{{"$ne", REMOVED_EVENT_NAME}}}}
-	result := r.collection.FindOne(ctx, filter)
+	result := collections.events.FindOne(ctx, filter)
 	if result != nil && result.Err() == mongo.ErrNoDocuments {
 		return false, nil
 	} else if result.Err() != nil {
@@ -194,16 +654,25 @@ func (r *MongoRepository[T]) Exists(ctx context.Context, objectId string) (bool,
 	return true, nil
 }
 
+// EventsSince returns at most limit events saved after timestamp.
+// The events of streams archived by ArchiveIdleStreams are not returned.
 func (r *MongoRepository[T]) EventsSince(ctx context.Context, timestamp time.Time, limit int) ([]Event, error) {
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return nil, err
+	}
 	records := make([]record, 0)
 
 	findOptions := options.Find()
 	findOptions.SetSort(bson.M{"timestamp": 1})
 	findOptions.SetLimit(int64(limit))
-	filter := bson.M{"timestamp": bson.M{
-		"$gte": timestamp.UnixNano(),
-	}}
-	listCursor, err := r.collection.Find(ctx, filter, findOptions)
+	filter := bson.M{
+		"timestamp": bson.M{
+			"$gte": timestamp.UnixNano(),
+		},
+		"name": bson.M{"$ne": ARCHIVED_EVENT_NAME},
+	}
+	listCursor, err := collections.events.Find(ctx, filter, findOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -218,6 +687,10 @@ func (r *MongoRepository[T]) EventsSince(ctx context.Context, timestamp time.Tim
 }
 
 func (r *MongoRepository[T]) ObjectEventsSinceVersion(ctx context.Context, objectID string, version int) ([]Event, error) {
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return nil, err
+	}
 	records := make([]record, 0)
 
 	findOptions := options.Find()
@@ -228,7 +701,7 @@ func (r *MongoRepository[T]) ObjectEventsSinceVersion(ctx context.Context, objec
 		},
 		"objectid": objectID,
 	}
-	listCursor, err := r.collection.Find(ctx, filter, findOptions)
+	listCursor, err := collections.events.Find(ctx, filter, findOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -242,54 +715,261 @@ func (r *MongoRepository[T]) ObjectEventsSinceVersion(ctx context.Context, objec
 	return fromRecords(records), nil
 }
 
-func (r *MongoRepository[T]) objectRepositoryEvents(ctx context.Context, objectID string) ([]Event, error) {
-	records := make([]record, 0)
+// IterateEventsSince streams the events saved after timestamp. A batchSize of 0 uses the server default.
+// The events of streams archived by ArchiveIdleStreams are skipped.
+func (r *MongoRepository[T]) IterateEventsSince(ctx context.Context, timestamp time.Time, batchSize int) (EventIterator, error) {
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	filter := bson.D{{"objectid", objectID}}
+	filter := bson.M{
+		"timestamp": bson.M{
+			"$gte": timestamp.UnixNano(),
+		},
+		"name": bson.M{"$ne": ARCHIVED_EVENT_NAME},
+	}
+	opts := options.Find().SetSort(bson.M{"timestamp": 1})
+	return iterateEvents(ctx, collections.events, filter, opts, batchSize)
+}
+
+// IterateObjectEvents streams the events of an object after sinceVersion, -1 for the whole stream.
+// A batchSize of 0 uses the server default.
+func (r *MongoRepository[T]) IterateObjectEvents(ctx context.Context, objectID string, sinceVersion int, batchSize int) (EventIterator, error) {
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{
+		"version": bson.M{
+			"$gt": sinceVersion,
+		},
+		"objectid": objectID,
+	}
 	opts := options.Find().SetSort(bson.M{"version": 1})
+	return iterateEvents(ctx, collections.events, filter, opts, batchSize)
+}
 
-	cursor, err := r.collection.Find(ctx, filter, opts)
+// QueryEvents pages through the events matching query, ordered by timestamp, object ID and version.
+// The name and timestamp indexes created by EventStoreMigrations serve the queries and their order.
+// The events of streams archived by ArchiveIdleStreams are not returned until the streams are rehydrated.
+func (r *MongoRepository[T]) QueryEvents(ctx context.Context, query EventQuery) (EventPage, error) {
+	collections, err := r.collections(ctx)
 	if err != nil {
-		return fromRecords(records), err
+		return EventPage{}, err
+	}
+	after, err := query.after()
+	if err != nil {
+		return EventPage{}, err
 	}
-	defer cursor.Close(ctx)
 
-	err = cursor.All(ctx, &records)
+	filters := bson.A{bson.D{{"name", bson.D{{"$ne", ARCHIVED_EVENT_NAME}}}}}
+	if len(query.Names) > 0 {
+		filters = append(filters, bson.D{{"name", bson.D{{"$in", query.Names}}}})
+	}
+	filters = append(filters, objectIDFilters(query.ObjectIDPrefix, query.ObjectType)...)
+	if !query.From.IsZero() {
+		filters = append(filters, bson.D{{"timestamp", bson.D{{"$gte", query.From.UnixNano()}}}})
+	}
+	if !query.To.IsZero() {
+		filters = append(filters, bson.D{{"timestamp", bson.D{{"$lt", query.To.UnixNano()}}}})
+	}
+	if after != nil {
+		filters = append(filters, bson.D{{"$or", bson.A{
+			bson.D{{"timestamp", bson.D{{"$gt", after.Timestamp}}}},
+			bson.D{{"timestamp", after.Timestamp}, {"objectid", bson.D{{"$gt", after.ObjectID}}}},
+			bson.D{{"timestamp", after.Timestamp}, {"objectid", after.ObjectID}, {"version", bson.D{{"$gt", after.Version}}}},
+		}}})
+	}
+
+	limit := query.limit()
+	opts := options.Find().
+		SetSort(bson.D{{"timestamp", 1}, {"objectid", 1}, {"version", 1}}).
+		SetLimit(int64(limit + 1))
+	cursor, err := collections.events.Find(ctx, bson.D{{"$and", filters}}, opts)
 	if err != nil {
-		return fromRecords(records), err
+		return EventPage{}, err
 	}
+	defer cursor.Close(ctx)
 
-	return fromRecords(records), nil
+	records := make([]record, 0)
+	if err := cursor.All(ctx, &records); err != nil {
+		return EventPage{}, err
+	}
+	return newEventPage(fromRecords(records), limit), nil
+}
+
+// objectIDFilters matches the object IDs with anchored regular expressions, which the objectid indexes serve
+func objectIDFilters(prefix, objectType string) bson.A {
+	filters := bson.A{}
+	if prefix != "" {
+		filters = append(filters, bson.D{{"objectid", primitive.Regex{Pattern: "^" + regexp.QuoteMeta(prefix)}}})
+	}
+	if objectType != "" {
+		filters = append(filters, bson.D{{"objectid", primitive.Regex{Pattern: identityPatternOf(objectType)}}})
+	}
+	return filters
 }
 
-func (r *MongoRepository[T]) lastSnapshot(ctx context.Context, objectID string) (*snapshot, error) {
-	if r.snapshotsCache != nil {
-		snap, ok := r.snapshotsCache.Get(objectID)
-		if ok {
-			snapshot := snap.(snapshot)
-			return &snapshot, nil
+// objectsFilter selects one event of each object matching filter with an ID after the given one: its event at
+// version 0 or, once archived, its stub. The events of a removed object are deleted but its removal event,
+// leaving it out. A stream being archived or rehydrated may have both.
+func objectsFilter(filter ObjectFilter, after string) bson.D {
+	filters := objectIDFilters(filter.ObjectIDPrefix, filter.ObjectType)
+	if after != "" {
+		filters = append(filters, bson.D{{"objectid", bson.D{{"$gt", after}}}})
+	}
+	filters = append(filters, bson.D{{"$or", bson.A{
+		bson.D{{"version", 0}, {"name", bson.D{{"$ne", REMOVED_EVENT_NAME}}}},
+		bson.D{{"name", ARCHIVED_EVENT_NAME}, {"version", bson.D{{"$gt", 0}}}},
+	}}})
+	return bson.D{{"$and", filters}}
+}
+
+// ListObjectIDs pages through the IDs of the objects matching filter, in ID order.
+// A page seeks past the cursor on the objectid and version index and on the name and objectid index,
+// reading about limit+1 events whatever the size of the collection.
+func (r *MongoRepository[T]) ListObjectIDs(ctx context.Context, filter ObjectFilter, cursor string, limit int) (ObjectIDPage, error) {
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return ObjectIDPage{}, err
+	}
+	after, err := decodeObjectCursor(cursor)
+	if err != nil {
+		return ObjectIDPage{}, err
+	}
+	limit = objectPageLimit(limit)
+
+	objectIDs := make([]string, 0, limit+1)
+	for len(objectIDs) <= limit {
+		wanted := limit + 1 - len(objectIDs)
+		opts := options.Find().
+			SetSort(bson.D{{"objectid", 1}}).
+			SetProjection(bson.D{{"_id", 0}, {"objectid", 1}}).
+			SetLimit(int64(wanted))
+		results, err := collections.events.Find(ctx, objectsFilter(filter, after), opts)
+		if err != nil {
+			return ObjectIDPage{}, err
+		}
+		objects := make([]struct {
+			ObjectID string `bson:"objectid"`
+		}, 0, wanted)
+		if err := results.All(ctx, &objects); err != nil {
+			return ObjectIDPage{}, err
+		}
+
+		for _, object := range objects {
+			// A stream being archived or rehydrated is found twice in a row
+			if object.ObjectID != after {
+				objectIDs = append(objectIDs, object.ObjectID)
+				after = object.ObjectID
+			}
 		}
+		if len(objects) < wanted {
+			break
+		}
+	}
+	return newObjectIDPage(objectIDs, limit), nil
+}
+
+// Count returns the number of objects matching filter. Only the event at version 0 or the stub of each
+// object is counted, through the same indexes as ListObjectIDs. A stream being archived or rehydrated
+// may be counted twice.
+func (r *MongoRepository[T]) Count(ctx context.Context, filter ObjectFilter) (int64, error) {
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return collections.events.CountDocuments(ctx, objectsFilter(filter, ""))
+}
+
+func iterateEvents(ctx context.Context, collection *mongo.Collection, filter interface{}, opts *options.FindOptions, batchSize int) (EventIterator, error) {
+	if batchSize > 0 {
+		opts.SetBatchSize(int32(batchSize))
+	}
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &mongoEventIterator{cursor: cursor}, nil
+}
+
+// mongoEventIterator decodes the events of a cursor, which fetches them from the server batch by batch
+type mongoEventIterator struct {
+	cursor *mongo.Cursor
+	event  Event
+	err    error
+}
+
+func (i *mongoEventIterator) Next(ctx context.Context) bool {
+	if i.err != nil {
+		return false
+	}
+	// The cursor only checks ctx when fetching a new batch
+	if err := ctx.Err(); err != nil {
+		i.err = err
+		return false
+	}
+	if !i.cursor.Next(ctx) {
+		i.err = i.cursor.Err()
+		return false
+	}
+
+	eventRecord := record{}
+	if err := i.cursor.Decode(&eventRecord); err != nil {
+		i.err = err
+		return false
+	}
+	i.event = fromRecords([]record{eventRecord})[0]
+	return true
+}
+
+func (i *mongoEventIterator) Event() Event {
+	return i.event
+}
+
+func (i *mongoEventIterator) Err() error {
+	return i.err
+}
+
+func (i *mongoEventIterator) Close(ctx context.Context) error {
+	return i.cursor.Close(ctx)
+}
+
+func (r *MongoRepository[T]) lastSnapshot(ctx context.Context, objectID string) (*Snapshot, error) {
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if snap, ok := r.snapshotsCache.Get(ctx, snapshotKey(collections, objectID)); ok {
+		return &snap, nil
 	}
 
 	filter := bson.D{{"objectid", objectID}}
-	result := r.snapshotsCollection.FindOne(ctx, filter)
+	result := collections.snapshots.FindOne(ctx, filter)
 	if result != nil && result.Err() == mongo.ErrNoDocuments {
 		return nil, nil
 	} else if result.Err() != nil {
 		return nil, result.Err()
 	}
 
-	lastSnapshot := snapshot{}
-	err := result.Decode(&lastSnapshot)
+	lastSnapshot := Snapshot{}
+	err = result.Decode(&lastSnapshot)
 	if err != nil {
 		return nil, err
 	}
+	r.snapshotsCache.Fill(ctx, snapshotKey(collections, objectID), lastSnapshot)
 
 	return &lastSnapshot, nil
 }
 
 func (r *MongoRepository[T]) lastVersion(ctx context.Context, objectID string) (int64, error) {
-	cursor, err := r.collection.Aggregate(
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return -1, err
+	}
+	cursor, err := collections.events.Aggregate(
 		ctx,
 		bson.A{
 			bson.D{{"$match", bson.D{{"objectid", objectID}}}},
@@ -321,8 +1001,12 @@ func (r *MongoRepository[T]) lastVersion(ctx context.Context, objectID string) (
 }
 
 func (r *MongoRepository[T]) alreadyRemoved(ctx context.Context, objectID string) (bool, error) {
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return false, err
+	}
 	filter := bson.D{{"objectid", objectID}, {"name", bson.D{{"$eq", REMOVED_EVENT_NAME}}}}
-	result := r.collection.FindOne(ctx, filter)
+	result := collections.events.FindOne(ctx, filter)
 	if result != nil && errors.Is(result.Err(), mongo.ErrNoDocuments) {
 		return false, nil
 	} else if result.Err() != nil {
@@ -332,6 +1016,10 @@ func (r *MongoRepository[T]) alreadyRemoved(ctx context.Context, objectID string
 }
 
 func (r *MongoRepository[T]) Remove(ctx context.Context, objectID string, object T) error {
+	collections, err := r.collections(ctx)
+	if err != nil {
+		return err
+	}
 	if alreadyRemoved, _ := r.alreadyRemoved(ctx, objectID); alreadyRemoved {
 		return nil
 	}
@@ -346,15 +1034,15 @@ func (r *MongoRepository[T]) Remove(ctx context.Context, objectID string, object
 	event := NewEvent(objectID, REMOVED_EVENT_NAME, int(lastVersion)+1, []byte{})
 	events := []Event{event}
 	records := toRecords(events)
-	_, err = r.collection.InsertMany(ctx, records)
+	_, err = collections.events.InsertMany(ctx, records)
 	if err != nil {
-		if mongo.IsDuplicateKeyError(err) {
+		if duplicates, ok := duplicateKeyWrites(err); ok && len(duplicates) == 1 && len(duplicates[versionIndex]) > 0 {
 			return ConcurrencyError
 		}
 		return err
 	}
 
-	_, err = r.collection.DeleteMany(
+	_, err = collections.events.DeleteMany(
 		ctx,
 		bson.D{
 			bson.E{"objectid", objectID},
@@ -365,12 +1053,18 @@ func (r *MongoRepository[T]) Remove(ctx context.Context, objectID string, object
 		return err
 	}
 
-	_, err = r.snapshotsCollection.DeleteMany(ctx, bson.D{{"objectid", object}})
+	_, err = collections.snapshots.DeleteMany(ctx, bson.D{{"objectid", objectID}})
 	if err != nil {
 		return err
 	}
+	r.snapshotsCache.Invalidate(ctx, snapshotKey(collections, objectID))
+	if r.options.Archive != nil {
+		if err := r.options.Archive.Delete(ctx, archiveKey(collections, objectID)); err != nil {
+			return err
+		}
+	}
 
-	r.publisher.Publish(events)
+	r.publisher.PublishContext(ctx, events)
 
 	return nil
 }
@@ -406,9 +1100,28 @@ func fromRecords(records []record) []Event {
 	return events
 }
 
+// MigrateMongoDB applies the pending event store migrations, then the migrations found in dir (see LoadMigrations).
+// Both sets are recorded in the migrations collection, under the "event_store" and "migrations" scopes.
 func MigrateMongoDB(mongoDB *mongo.Database, dir string) error {
-	collection := mongoDB.Collection("event_store")
-	_, err := collection.Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+	ctx := context.Background()
+	_, err := NewMigrator(mongoDB, "event_store", EventStoreMigrations("event_store", "domain_event_snapshots")).Migrate(ctx)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		return nil
+	}
+	_, err = NewMigrator(mongoDB, "migrations", migrations).Migrate(ctx)
+	return err
+}
+
+func createEventStoreIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
 		{
 			Keys: bson.D{
 				bson.E{
@@ -429,7 +1142,7 @@ func MigrateMongoDB(mongoDB *mongo.Database, dir string) error {
 					Value: 1,
 				},
 			},
-			Options: options.Index().SetName("objectID_version_unique").SetUnique(true).SetBackground(true),
+			Options: options.Index().SetName(versionIndex).SetUnique(true).SetBackground(true),
 		},
 		{
 			Keys: bson.D{
@@ -438,7 +1151,7 @@ func MigrateMongoDB(mongoDB *mongo.Database, dir string) error {
 					Value: 1,
 				},
 			},
-			Options: options.Index().SetName("eventid_unique").SetUnique(true).SetBackground(true),
+			Options: options.Index().SetName(eventIDIndex).SetUnique(true).SetBackground(true),
 		},
 		{
 			Keys: bson.D{
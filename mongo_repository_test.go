@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -115,6 +117,10 @@ func TestMongoSave(t *testing.T) {
 		objectCopy.SetGrade("c")
 		err = repo.Save(context.Background(), &objectCopy)
 		assert.ErrorIs(t, err, ConcurrencyError)
+		var conflict *VersionConflictError
+		assert.ErrorAs(t, err, &conflict)
+		assert.Equal(t, 1, conflict.Expected)
+		assert.Equal(t, 2, conflict.Actual)
 	})
 }
 
@@ -136,7 +142,7 @@ func TestMongoPublished(t *testing.T) {
 	err = repo.Save(context.Background(), &object)
 	assert.NoError(t, err)
 
-	if len(receiver.events) != 1 {
+	if len(receiver.received()) != 1 {
 		t.Error("No events received")
 		t.FailNow()
 	}
@@ -162,7 +168,7 @@ func TestMongoPublishedNoWait(t *testing.T) {
 	assert.NoError(t, err)
 	time.Sleep(500 * time.Millisecond)
 
-	if len(receiver.events) != 1 {
+	if len(receiver.received()) != 1 {
 		t.Error("No events received")
 		t.FailNow()
 	}
@@ -634,3 +640,487 @@ func TestMongoRemove(t *testing.T) {
 		assert.NoError(t, err)
 	})
 }
+
+func TestMongoCollectionOptions(t *testing.T) {
+	client, database := connectTestMongo(t)
+	t.Cleanup(func() { client.Disconnect(context.TODO()) })
+
+	publisher := NewEventPublisher()
+	prefix := "test_" + uuid.New().String()[:8] + "_"
+	t.Cleanup(func() {
+		for _, name := range []string{"students", "domain_event_snapshots"} {
+			assert.NoError(t, database.Collection(prefix+name).Drop(context.Background()))
+		}
+		_, err := database.Collection("migrations").DeleteMany(context.Background(), bson.D{{Key: "scope", Value: prefix + "students"}})
+		assert.NoError(t, err)
+	})
+	repo, err := NewMongoRepositoryWithOptions[*Student](database, &publisher, MongoRepositoryOptions{
+		EventsCollection: "students",
+		CollectionPrefix: prefix,
+	})
+	assert.NoError(t, err)
+	object := Student{ID: uuid.New().String()}
+	object.SetGrade("a")
+	assert.NoError(t, repo.Save(context.Background(), &object))
+
+	count, err := database.Collection(prefix+"students").CountDocuments(context.Background(), bson.D{{"objectid", object.ID}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.Len(t, eventStreamFor(t, database, object.ID), 0)
+}
+
+// dropTestTenant removes the database or the collections and migration records of a tenant once the test is done
+func dropTestTenant(t *testing.T, database *mongo.Database, mode TenantMode, tenantID string) {
+	t.Cleanup(func() {
+		ctx := context.Background()
+		if mode == TenantDatabase {
+			assert.NoError(t, database.Client().Database(database.Name()+"_"+tenantID).Drop(ctx))
+			return
+		}
+
+		prefix := bson.D{{Key: "$regex", Value: "^" + tenantID + "_"}}
+		names, err := database.ListCollectionNames(ctx, bson.D{{Key: "name", Value: prefix}})
+		assert.NoError(t, err)
+		for _, name := range names {
+			assert.NoError(t, database.Collection(name).Drop(ctx))
+		}
+		_, err = database.Collection("migrations").DeleteMany(ctx, bson.D{{Key: "scope", Value: prefix}})
+		assert.NoError(t, err)
+	})
+}
+
+func TestMongoTenants(t *testing.T) {
+	for _, mode := range []TenantMode{TenantCollection, TenantDatabase} {
+		client, database := connectTestMongo(t)
+		t.Cleanup(func() { client.Disconnect(context.TODO()) })
+
+		publisher := NewEventPublisher()
+		repo, err := NewMongoRepositoryWithOptions[*Student](database, &publisher, MongoRepositoryOptions{
+			Tenancy: mode,
+		})
+		assert.NoError(t, err)
+		tenantAID := "a" + uuid.New().String()[:8]
+		tenantBID := "b" + uuid.New().String()[:8]
+		dropTestTenant(t, database, mode, tenantAID)
+		dropTestTenant(t, database, mode, tenantBID)
+		tenantA := WithTenant(context.Background(), tenantAID)
+		tenantB := WithTenant(context.Background(), tenantBID)
+
+		object := Student{ID: uuid.New().String()}
+		object.SetGrade("a")
+		assert.NoError(t, repo.Save(tenantA, &object))
+
+		exists, err := repo.Exists(tenantA, object.ID)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+		exists, err = repo.Exists(tenantB, object.ID)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+		assert.Len(t, eventStreamFor(t, database, object.ID), 0)
+	}
+}
+
+func TestMongoTenantRequired(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+	publisher := NewEventPublisher()
+	repo, err := NewMongoRepositoryWithOptions[*Student](database, &publisher, MongoRepositoryOptions{
+		Tenancy: TenantCollection,
+	})
+	assert.NoError(t, err)
+
+	_, err = repo.Exists(context.Background(), "object")
+	assert.ErrorIs(t, err, ErrMissingTenant)
+	_, err = repo.Exists(WithTenant(context.Background(), "../admin"), "object")
+	assert.ErrorIs(t, err, ErrInvalidTenant)
+}
+
+func TestMongoLoadAfterSnapshot(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	publisher := NewEventPublisher()
+	repo, err := NewMongoRepository[*StudentMemento](database, &publisher)
+	assert.NoError(t, err)
+	object := StudentMemento{EventStream: &Stream{}, ID: uuid.New().String()}
+	for i := 0; i < 600; i++ {
+		object.SetGrade(fmt.Sprintf("a%d", i))
+	}
+	assert.NoError(t, repo.Save(context.Background(), &object))
+	object.SetGrade("last")
+	assert.NoError(t, repo.Save(context.Background(), &object))
+
+	loaded := StudentMemento{EventStream: &Stream{}}
+	assert.NoError(t, repo.Load(context.Background(), object.ID, &loaded))
+	assert.Equal(t, "last", loaded.grade)
+	assert.Equal(t, object.LastVersion(), loaded.LastVersion())
+
+	loaded.SetGrade("next")
+	assert.NoError(t, repo.Save(context.Background(), &loaded))
+}
+
+func TestMongoLoadMany(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	publisher := NewEventPublisher()
+	repo, err := NewMongoRepository[*StudentMemento](database, &publisher)
+	assert.NoError(t, err)
+	snapshotted := StudentMemento{EventStream: &Stream{}, ID: uuid.New().String()}
+	for i := 0; i < 600; i++ {
+		snapshotted.SetGrade(fmt.Sprintf("a%d", i))
+	}
+	assert.NoError(t, repo.Save(context.Background(), &snapshotted))
+	snapshotted.SetGrade("last")
+	assert.NoError(t, repo.Save(context.Background(), &snapshotted))
+
+	plain := StudentMemento{EventStream: &Stream{}, ID: uuid.New().String()}
+	plain.SetGrade("b")
+	assert.NoError(t, repo.Save(context.Background(), &plain))
+
+	removed := StudentMemento{EventStream: &Stream{}, ID: uuid.New().String()}
+	removed.SetGrade("c")
+	assert.NoError(t, repo.Save(context.Background(), &removed))
+	assert.NoError(t, repo.Remove(context.Background(), removed.ID, &removed))
+	unknown := uuid.New().String()
+
+	objects, err := repo.LoadMany(context.Background(), []string{snapshotted.ID, plain.ID, removed.ID, unknown}, func() *StudentMemento {
+		return &StudentMemento{EventStream: &Stream{}}
+	})
+
+	var missing *MissingObjectsError
+	assert.ErrorAs(t, err, &missing)
+	assert.ElementsMatch(t, []string{removed.ID, unknown}, missing.ObjectIDs)
+	assert.Len(t, objects, 2)
+	assert.Equal(t, "last", objects[snapshotted.ID].grade)
+	assert.Equal(t, snapshotted.LastVersion(), objects[snapshotted.ID].LastVersion())
+	assert.Equal(t, "b", objects[plain.ID].grade)
+}
+
+func TestMongoIterateEvents(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	publisher := NewEventPublisher()
+	repo, err := NewMongoRepository[*Student](database, &publisher)
+	assert.NoError(t, err)
+	before := time.Now()
+	object := Student{ID: uuid.New().String()}
+	for i := 0; i < 25; i++ {
+		object.SetGrade(fmt.Sprintf("a%d", i))
+	}
+	assert.NoError(t, repo.Save(context.Background(), &object))
+
+	events, err := repo.IterateObjectEvents(context.Background(), object.ID, -1, 10)
+	assert.NoError(t, err)
+	count := 0
+	for events.Next(context.Background()) {
+		assert.Equal(t, count, events.Event().Version())
+		count++
+	}
+	assert.NoError(t, events.Err())
+	assert.NoError(t, events.Close(context.Background()))
+	assert.Equal(t, 25, count)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err = repo.IterateEventsSince(ctx, before, 10)
+	assert.NoError(t, err)
+	defer events.Close(context.Background())
+	assert.True(t, events.Next(ctx))
+	cancel()
+	assert.False(t, events.Next(ctx))
+	assert.ErrorIs(t, events.Err(), context.Canceled)
+}
+
+func TestMongoRemoveInvalidatesSnapshot(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	publisher := NewEventPublisher()
+	cache, err := NewRistrettoSnapshotCache(RistrettoSnapshotCacheOptions{})
+	assert.NoError(t, err)
+	repo, err := NewMongoRepositoryWithOptions[*StudentMemento](database, &publisher, MongoRepositoryOptions{SnapshotCache: cache})
+	assert.NoError(t, err)
+	object := StudentMemento{EventStream: &Stream{}, ID: uuid.New().String()}
+	for i := 0; i < 600; i++ {
+		object.SetGrade(fmt.Sprintf("a%d", i))
+	}
+	assert.NoError(t, repo.Save(context.Background(), &object))
+	cache.Wait()
+
+	collections, err := repo.collections(context.Background())
+	assert.NoError(t, err)
+	_, ok := cache.Get(context.Background(), snapshotKey(collections, object.ID))
+	assert.True(t, ok)
+
+	assert.NoError(t, repo.Remove(context.Background(), object.ID, &object))
+	_, ok = cache.Get(context.Background(), snapshotKey(collections, object.ID))
+	assert.False(t, ok)
+	count, err := collections.snapshots.CountDocuments(context.Background(), bson.M{"objectid": object.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestMongoArchiveIdleStreams(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	publisher := NewEventPublisher()
+	store, err := NewFileArchiveStore(t.TempDir())
+	assert.NoError(t, err)
+	prefix := uuid.New().String() + "_"
+	repo, err := NewMongoRepositoryWithOptions[*Student](database, &publisher, MongoRepositoryOptions{
+		CollectionPrefix: prefix,
+		Archive:          store,
+	})
+	assert.NoError(t, err)
+	defer func() {
+		for _, name := range []string{"event_store", "domain_event_snapshots"} {
+			assert.NoError(t, database.Collection(prefix+name).Drop(context.Background()))
+		}
+	}()
+	cold := Student{ID: uuid.New().String()}
+	cold.SetGrade("a")
+	cold.SetGrade("b")
+	assert.NoError(t, repo.Save(context.Background(), &cold))
+	time.Sleep(100 * time.Millisecond)
+	hot := Student{ID: uuid.New().String()}
+	hot.SetGrade("c")
+	assert.NoError(t, repo.Save(context.Background(), &hot))
+
+	archived, err := repo.ArchiveIdleStreams(context.Background(), 50*time.Millisecond, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{cold.ID}, archived)
+
+	collections, err := repo.collections(context.Background())
+	assert.NoError(t, err)
+	count, err := collections.events.CountDocuments(context.Background(), bson.M{"objectid": cold.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	exists, err := repo.Exists(context.Background(), cold.ID)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+
+	loaded := Student{}
+	assert.NoError(t, repo.Load(context.Background(), cold.ID, &loaded))
+	assert.Equal(t, "b", loaded.grade)
+	assert.Equal(t, 2, loaded.LastVersion())
+	count, err = collections.events.CountDocuments(context.Background(), bson.M{"objectid": cold.ID, "rehydrated": true})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+	_, err = store.Get(context.Background(), archiveKey(collections, cold.ID))
+	assert.ErrorIs(t, err, ErrNotArchived)
+
+	time.Sleep(100 * time.Millisecond)
+	archived, err = repo.ArchiveIdleStreams(context.Background(), 50*time.Millisecond, 0)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{cold.ID, hot.ID}, archived)
+
+	// A rehydration interrupted after inserting some events is completed
+	events, err := store.Get(context.Background(), archiveKey(collections, cold.ID))
+	assert.NoError(t, err)
+	_, err = collections.events.InsertMany(context.Background(), toRecords(events[:1]))
+	assert.NoError(t, err)
+	objects, err := repo.LoadMany(context.Background(), []string{cold.ID, hot.ID}, func() *Student { return &Student{} })
+	assert.NoError(t, err)
+	assert.Equal(t, "b", objects[cold.ID].grade)
+	assert.Equal(t, "c", objects[hot.ID].grade)
+}
+
+func TestMongoListArchivedObjectIDs(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	publisher := NewEventPublisher()
+	store, err := NewFileArchiveStore(t.TempDir())
+	assert.NoError(t, err)
+	prefix := uuid.New().String() + "_"
+	repo, err := NewMongoRepositoryWithOptions[*Student](database, &publisher, MongoRepositoryOptions{
+		CollectionPrefix: prefix,
+		Archive:          store,
+	})
+	assert.NoError(t, err)
+	defer func() {
+		for _, name := range []string{"event_store", "domain_event_snapshots"} {
+			assert.NoError(t, database.Collection(prefix+name).Drop(context.Background()))
+		}
+	}()
+
+	students := make([]string, 0)
+	for i := 0; i < 4; i++ {
+		object := Student{ID: NewIdentity("Student")}
+		object.SetGrade("a")
+		object.SetGrade("b")
+		assert.NoError(t, repo.Save(context.Background(), &object))
+		students = append(students, object.ID)
+	}
+	time.Sleep(100 * time.Millisecond)
+	archived, err := repo.ArchiveIdleStreams(context.Background(), 50*time.Millisecond, 0)
+	assert.NoError(t, err)
+	assert.Len(t, archived, 4)
+	for i := 0; i < 3; i++ {
+		object := Student{ID: NewIdentity("Student")}
+		object.SetGrade("c")
+		assert.NoError(t, repo.Save(context.Background(), &object))
+		students = append(students, object.ID)
+	}
+
+	count, err := repo.Count(context.Background(), ObjectFilter{ObjectType: "Student"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), count)
+
+	listed := make([]string, 0)
+	cursor := ""
+	for pages := 0; pages < 10; pages++ {
+		page, err := repo.ListObjectIDs(context.Background(), ObjectFilter{ObjectType: "Student"}, cursor, 2)
+		assert.NoError(t, err)
+		listed = append(listed, page.ObjectIDs...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	sort.Strings(students)
+	assert.Equal(t, students, listed)
+}
+
+func TestMongoSaveExpecting(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	publisher := NewEventPublisher()
+	repo, err := NewMongoRepository[*Student](database, &publisher)
+	assert.NoError(t, err)
+	object := Student{ID: uuid.NewString()}
+
+	object.SetGrade("a")
+	assert.ErrorIs(t, repo.SaveExpecting(context.Background(), &object, StreamExists), ConcurrencyError)
+	assert.NoError(t, repo.SaveExpecting(context.Background(), &object, NoStream))
+
+	object.SetGrade("b")
+	err = repo.SaveExpecting(context.Background(), &object, NoStream)
+	var conflict *VersionConflictError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, NoStream, conflict.Expected)
+	assert.Equal(t, 1, conflict.Actual)
+
+	err = repo.SaveExpecting(context.Background(), &object, 3)
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, 3, conflict.Expected)
+	assert.Equal(t, 1, conflict.Actual)
+
+	assert.NoError(t, repo.SaveExpecting(context.Background(), &object, 1))
+	object.SetGrade("c")
+	assert.NoError(t, repo.SaveExpecting(context.Background(), &object, StreamExists))
+	object.SetGrade("d")
+	assert.NoError(t, repo.SaveExpecting(context.Background(), &object, Any))
+	assert.Len(t, eventStreamFor(t, database, object.ObjectID()), 4)
+}
+
+func TestDuplicateKeyWrites(t *testing.T) {
+	duplicate := func(index int, indexName string) mongo.BulkWriteError {
+		return mongo.BulkWriteError{WriteError: mongo.WriteError{
+			Index:   index,
+			Code:    11000,
+			Message: "E11000 duplicate key error collection: testdb.event_store index: " + indexName + " dup key: { id: \"x\" }",
+		}}
+	}
+
+	duplicates, ok := duplicateKeyWrites(mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{duplicate(0, versionIndex)}})
+	assert.True(t, ok)
+	assert.Equal(t, map[string][]int{versionIndex: {0}}, duplicates)
+
+	duplicates, ok = duplicateKeyWrites(mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{
+		duplicate(0, eventIDIndex),
+		duplicate(2, versionIndex),
+		duplicate(3, eventIDIndex),
+	}})
+	assert.True(t, ok)
+	assert.Equal(t, map[string][]int{eventIDIndex: {0, 3}, versionIndex: {2}}, duplicates)
+
+	_, ok = duplicateKeyWrites(mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{
+		duplicate(0, eventIDIndex),
+		{WriteError: mongo.WriteError{Index: 1, Code: 121, Message: "Document failed validation"}},
+	}})
+	assert.False(t, ok)
+	_, ok = duplicateKeyWrites(errors.New("network error"))
+	assert.False(t, ok)
+}
+
+func TestMongoQueryEvents(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	publisher := NewEventPublisher()
+	repo, err := NewMongoRepository[*Student](database, &publisher)
+	assert.NoError(t, err)
+	objectType := "Student" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	from := time.Now()
+	for i := 0; i < 3; i++ {
+		object := Student{ID: NewIdentity(objectType)}
+		object.SetGrade("a")
+		object.SetGrade("b")
+		assert.NoError(t, repo.Save(context.Background(), &object))
+	}
+	other := Student{ID: uuid.NewString()}
+	other.SetGrade("c")
+	assert.NoError(t, repo.Save(context.Background(), &other))
+
+	query := EventQuery{ObjectType: objectType, Names: []string{"GradeSet"}, From: from, To: time.Now(), Limit: 4}
+	page, err := repo.QueryEvents(context.Background(), query)
+	assert.NoError(t, err)
+	assert.Len(t, page.Events, 4)
+	assert.NotEmpty(t, page.NextCursor)
+
+	query.Cursor = page.NextCursor
+	next, err := repo.QueryEvents(context.Background(), query)
+	assert.NoError(t, err)
+	assert.Len(t, next.Events, 2)
+	assert.Empty(t, next.NextCursor)
+	assert.True(t, positionOf(page.Events[3]).before(positionOf(next.Events[0])))
+
+	page, err = repo.QueryEvents(context.Background(), EventQuery{ObjectIDPrefix: other.ID})
+	assert.NoError(t, err)
+	assert.Len(t, page.Events, 1)
+}
+
+func TestMongoListObjectIDs(t *testing.T) {
+	client, database := connectTestMongo(t)
+	defer client.Disconnect(context.TODO())
+
+	publisher := NewEventPublisher()
+	repo, err := NewMongoRepository[*Student](database, &publisher)
+	assert.NoError(t, err)
+	objectType := "School-Student" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	students := make([]string, 0)
+	for i := 0; i < 5; i++ {
+		object := Student{ID: NewIdentity(objectType)}
+		object.SetGrade("a")
+		object.SetGrade("b")
+		assert.NoError(t, repo.Save(context.Background(), &object))
+		students = append(students, object.ID)
+	}
+	for _, objectID := range []string{NewIdentity(objectType + "-Class"), objectType + "-" + strings.Repeat("-", 36)} {
+		other := Student{ID: objectID}
+		other.SetGrade("a")
+		assert.NoError(t, repo.Save(context.Background(), &other))
+	}
+	removed := Student{ID: NewIdentity(objectType)}
+	removed.SetGrade("a")
+	assert.NoError(t, repo.Save(context.Background(), &removed))
+	assert.NoError(t, repo.Remove(context.Background(), removed.ID, &removed))
+
+	count, err := repo.Count(context.Background(), ObjectFilter{ObjectType: objectType})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+
+	page, err := repo.ListObjectIDs(context.Background(), ObjectFilter{ObjectType: objectType}, "", 3)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, page.NextCursor)
+	next, err := repo.ListObjectIDs(context.Background(), ObjectFilter{ObjectType: objectType}, page.NextCursor, 3)
+	assert.NoError(t, err)
+	assert.Empty(t, next.NextCursor)
+
+	sort.Strings(students)
+	assert.Equal(t, students, append(page.ObjectIDs, next.ObjectIDs...))
+}
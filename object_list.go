@@ -0,0 +1,84 @@
+package goddd
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+)
+
+// DefaultObjectPageLimit is the number of object IDs of a page when ListObjectIDs is given no limit
+const DefaultObjectPageLimit = 100
+
+// ObjectFilter selects the objects listed or counted by a repository. Removed objects are never selected.
+type ObjectFilter struct {
+	// ObjectIDPrefix keeps the objects whose ID starts with the prefix
+	ObjectIDPrefix string
+	// ObjectType keeps the objects whose identity was created by NewIdentity with this type
+	ObjectType string
+}
+
+// ObjectIDPage is a page of the object IDs matching an ObjectFilter
+type ObjectIDPage struct {
+	ObjectIDs []string
+	// NextCursor is the cursor of the next page, empty on the last page
+	NextCursor string
+}
+
+func (f ObjectFilter) matches(objectID string) bool {
+	if !strings.HasPrefix(objectID, f.ObjectIDPrefix) {
+		return false
+	}
+	return f.ObjectType == "" || ObjectType(objectID) == f.ObjectType
+}
+
+func objectPageLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultObjectPageLimit
+	}
+	return limit
+}
+
+// decodeObjectCursor returns the last object ID of the previous page
+func decodeObjectCursor(cursor string) (string, error) {
+	after, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+	return string(after), nil
+}
+
+// newObjectIDPage builds a page from up to limit+1 sorted IDs, the extra one telling there is a next page
+func newObjectIDPage(objectIDs []string, limit int) ObjectIDPage {
+	if len(objectIDs) <= limit {
+		return ObjectIDPage{ObjectIDs: objectIDs}
+	}
+	objectIDs = objectIDs[:limit]
+	return ObjectIDPage{
+		ObjectIDs:  objectIDs,
+		NextCursor: base64.RawURLEncoding.EncodeToString([]byte(objectIDs[limit-1])),
+	}
+}
+
+// liveObjectIDs returns the sorted IDs of the objects of events matching filter, leaving out the removed ones
+func liveObjectIDs(events []Event, filter ObjectFilter) []string {
+	removed := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, event := range events {
+		if !filter.matches(event.ObjectId()) {
+			continue
+		}
+		if event.Name() == REMOVED_EVENT_NAME {
+			removed[event.ObjectId()] = true
+		}
+		seen[event.ObjectId()] = true
+	}
+
+	objectIDs := make([]string, 0, len(seen))
+	for objectID := range seen {
+		if !removed[objectID] {
+			objectIDs = append(objectIDs, objectID)
+		}
+	}
+	sort.Strings(objectIDs)
+	return objectIDs
+}
@@ -7,6 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,13 +17,103 @@ import (
 var ConcurrencyError = errors.New("concurrency error while saving")
 var InvalidUpdateCallback = errors.New("callback should return a non nil object if error is nil")
 
+// Special expected versions of SaveExpecting
+const (
+	// Any saves the object whatever the version of its stream
+	Any = -1
+	// NoStream only saves an object that was never saved
+	NoStream = -2
+	// StreamExists only saves an object that exists
+	StreamExists = -3
+)
+
 type Repository[T DomainObject] interface {
 	Save(ctx context.Context, object T) error
+	// SaveExpecting saves object if its stream is at expectedVersion, the LastVersion of the object before its
+	// unsaved events, or matches one of Any, NoStream and StreamExists. It returns a *VersionConflictError otherwise.
+	SaveExpecting(ctx context.Context, object T, expectedVersion int) error
 	Load(ctx context.Context, objectID string, object T) error
 	Exists(ctx context.Context, objectID string) (bool, error)
 	EventsSince(ctx context.Context, time time.Time, limit int) ([]Event, error)
 	Update(ctx context.Context, objectID string, object T, nbRetries int, updater func(T) (T, error)) (T, error)
 	Remove(ctx context.Context, objectID string, object T) error
+	// LoadMany loads the objects created by factory with the given IDs. Objects that do not exist are
+	// left out of the returned map and reported by a *MissingObjectsError.
+	LoadMany(ctx context.Context, objectIDs []string, factory func() T) (map[string]T, error)
+	// IterateEventsSince streams the events saved after timestamp, oldest first, fetching batchSize events at a time
+	IterateEventsSince(ctx context.Context, timestamp time.Time, batchSize int) (EventIterator, error)
+	// IterateObjectEvents streams the events of an object after the given version, fetching batchSize events at a time
+	IterateObjectEvents(ctx context.Context, objectID string, sinceVersion int, batchSize int) (EventIterator, error)
+	// QueryEvents returns a page of the events matching query
+	QueryEvents(ctx context.Context, query EventQuery) (EventPage, error)
+	// ListObjectIDs returns up to limit IDs of the objects matching filter, in ID order, after the given cursor.
+	// The cursor of the first page is empty, the next ones are given by the previous page.
+	ListObjectIDs(ctx context.Context, filter ObjectFilter, cursor string, limit int) (ObjectIDPage, error)
+	// Count returns the number of objects matching filter
+	Count(ctx context.Context, filter ObjectFilter) (int64, error)
+}
+
+// MissingObjectsError lists the objects LoadMany could not find
+type MissingObjectsError struct {
+	ObjectIDs []string
+}
+
+func (e *MissingObjectsError) Error() string {
+	return fmt.Sprintf("%d unknown objects : %s", len(e.ObjectIDs), strings.Join(e.ObjectIDs, ", "))
+}
+
+// VersionConflictError is the ConcurrencyError of a stream which is not at the expected version.
+// The version of a stream is its number of saved events.
+type VersionConflictError struct {
+	ObjectID string
+	Expected int
+	Actual   int
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s : %s expected at version %s, got %d", ConcurrencyError.Error(), e.ObjectID, expectedVersionName(e.Expected), e.Actual)
+}
+
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ConcurrencyError
+}
+
+func expectedVersionName(version int) string {
+	switch version {
+	case Any:
+		return "any"
+	case NoStream:
+		return "no stream"
+	case StreamExists:
+		return "existing stream"
+	default:
+		return fmt.Sprint(version)
+	}
+}
+
+// checkExpectedVersion returns a *VersionConflictError if a stream at actualVersion does not match expectedVersion
+func checkExpectedVersion(objectID string, expectedVersion, actualVersion int, exists bool) error {
+	switch expectedVersion {
+	case Any:
+		return nil
+	case NoStream:
+		if actualVersion == 0 {
+			return nil
+		}
+	case StreamExists:
+		if exists {
+			return nil
+		}
+	default:
+		if expectedVersion == actualVersion {
+			return nil
+		}
+	}
+	return &VersionConflictError{
+		ObjectID: objectID,
+		Expected: expectedVersion,
+		Actual:   actualVersion,
+	}
 }
 
 func unsavedEvents(objectEvents []Event, knownEventIDs []string) []Event {
@@ -45,6 +137,26 @@ func NewIdentity(objectType string) string {
 	return fmt.Sprintf("%s-%s", objectType, uuid.New().String())
 }
 
+// identitySuffix matches the UUID NewIdentity appends to the object type, at the end of the identity
+const identitySuffix = `-[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\z`
+
+var identityPattern = regexp.MustCompile(`(?s)^(.+)` + identitySuffix)
+
+// identityPatternOf returns the pattern of the identities created by NewIdentity with objectType
+func identityPatternOf(objectType string) string {
+	return "^" + regexp.QuoteMeta(objectType) + identitySuffix
+}
+
+// ObjectType returns the type an identity was created with by NewIdentity,
+// or an empty string if objectID was not created by NewIdentity.
+func ObjectType(objectID string) string {
+	match := identityPattern.FindStringSubmatch(objectID)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
 func Encode(object interface{}) ([]byte, error) {
 	var data bytes.Buffer
 	encoder := gob.NewEncoder(&data)
@@ -91,3 +203,42 @@ func repoUpdate[T DomainObject](ctx context.Context, repo Repository[T], objectI
 	}
 	return object, err
 }
+
+func repoLoadMany[T DomainObject](ctx context.Context, repo Repository[T], objectIDs []string, factory func() T) (map[string]T, error) {
+	objects := make(map[string]T, len(objectIDs))
+	missing := make([]string, 0)
+	for _, objectID := range uniqueIDs(objectIDs) {
+		exists, err := repo.Exists(ctx, objectID)
+		if err != nil {
+			return objects, err
+		}
+		if !exists {
+			missing = append(missing, objectID)
+			continue
+		}
+
+		object := factory()
+		if err := repo.Load(ctx, objectID, object); err != nil {
+			return objects, err
+		}
+		objects[objectID] = object
+	}
+
+	if len(missing) > 0 {
+		return objects, &MissingObjectsError{ObjectIDs: missing}
+	}
+	return objects, nil
+}
+
+func uniqueIDs(objectIDs []string) []string {
+	seen := make(map[string]struct{}, len(objectIDs))
+	unique := make([]string, 0, len(objectIDs))
+	for _, objectID := range objectIDs {
+		if _, ok := seen[objectID]; ok {
+			continue
+		}
+		seen[objectID] = struct{}{}
+		unique = append(unique, objectID)
+	}
+	return unique
+}
@@ -0,0 +1,32 @@
+package goddd
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObjectType(t *testing.T) {
+	assert.Equal(t, "Student", ObjectType(NewIdentity("Student")))
+	assert.Equal(t, "School-Student", ObjectType(NewIdentity("School-Student")))
+	assert.Equal(t, "", ObjectType("Student"))
+	assert.Equal(t, "", ObjectType("ObjectID"))
+	assert.Equal(t, "", ObjectType("Student-"+"not-a-uuid-but-as-long-as-one-for-sure"))
+}
+
+func TestIdentityPatternMatchesObjectType(t *testing.T) {
+	objectIDs := []string{
+		NewIdentity("School-Student"),
+		NewIdentity("School-Student-Class"),
+		NewIdentity("Student"),
+		"School-Student-" + strings.Repeat("-", 36),
+		"School-Student-" + strings.ToUpper(strings.TrimPrefix(NewIdentity(""), "-")),
+		NewIdentity("School-Student") + "\n",
+	}
+	pattern := regexp.MustCompile(identityPatternOf("School-Student"))
+	for _, objectID := range objectIDs {
+		assert.Equal(t, ObjectType(objectID) == "School-Student", pattern.MatchString(objectID), objectID)
+	}
+}
@@ -0,0 +1,88 @@
+package goddd
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Backoff returns the delay to wait before the given retry attempt (starting at 1)
+type Backoff func(attempt int) time.Duration
+
+// ConstantBackoff waits the same delay before every retry
+func ConstantBackoff(delay time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		return delay
+	}
+}
+
+// ExponentialBackoff doubles the delay on every retry, starting at initial and capped at max
+func ExponentialBackoff(initial, max time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		delay := initial
+		for i := 1; i < attempt && delay < max; i++ {
+			delay *= 2
+		}
+		if delay > max {
+			return max
+		}
+		return delay
+	}
+}
+
+// PermanentError is implemented by errors that retrying cannot fix
+type PermanentError interface {
+	error
+	Permanent() bool
+}
+
+// RetryPolicy configures how many times and how fast a failing operation is retried
+type RetryPolicy struct {
+	MaxRetries int
+	Backoff    Backoff
+}
+
+// Run executes operation until it succeeds, fails permanently or retries are exhausted.
+// It returns the number of attempts made and the last error.
+func (p RetryPolicy) Run(ctx context.Context, operation func() error) (int, error) {
+	attempts := 0
+	for {
+		attempts++
+		err := operation()
+		if err == nil || attempts > p.MaxRetries || isPermanent(err) {
+			return attempts, err
+		}
+
+		if sleep(ctx, p.delay(attempts)) != nil {
+			return attempts, err
+		}
+	}
+}
+
+func isPermanent(err error) bool {
+	var permanent PermanentError
+	return errors.As(err, &permanent) && permanent.Permanent()
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	if p.Backoff == nil {
+		return 0
+	}
+	return p.Backoff(attempt)
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,46 @@
+package goddd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10, 100)
+
+	assert.EqualValues(t, 10, backoff(1))
+	assert.EqualValues(t, 20, backoff(2))
+	assert.EqualValues(t, 80, backoff(4))
+	assert.EqualValues(t, 100, backoff(5))
+	assert.EqualValues(t, 100, backoff(50))
+}
+
+func TestRetryPolicyStopsOnSuccess(t *testing.T) {
+	calls := 0
+	policy := RetryPolicy{MaxRetries: 5}
+
+	attempts, err := policy.Run(context.Background(), func() error {
+		calls++
+		if calls < 2 {
+			return errors.New("bam")
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+}
+
+func TestRetryPolicyExhausted(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 2}
+
+	attempts, err := policy.Run(context.Background(), func() error {
+		return errors.New("bam")
+	})
+
+	assert.EqualError(t, err, "bam")
+	assert.Equal(t, 3, attempts)
+}
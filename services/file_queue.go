@@ -0,0 +1,557 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrQueueClosed = errors.New("queue closed")
+
+// SyncPolicy tells when a FileQueueService flushes its files to disk
+type SyncPolicy int
+
+const (
+	// SyncAlways flushes after every Push and Pop. Nothing acknowledged is lost on power failure.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval flushes every FileQueueOptions.SyncInterval in the background, and on Push once it elapsed.
+	// The values pushed or popped during the last interval may be lost or received again on power failure.
+	SyncInterval
+	// SyncNever leaves flushing to the operating system
+	SyncNever
+)
+
+const (
+	segmentExtension = ".seg"
+	offsetFileName   = "offset"
+	recordHeaderSize = 8
+)
+
+// FileQueueOptions configures a FileQueueService
+type FileQueueOptions struct {
+	// SegmentSize is the size after which a new segment file is started. Defaults to 64MB.
+	SegmentSize int64
+	// Sync is the fsync policy, SyncAlways by default
+	Sync SyncPolicy
+	// SyncInterval is the flush period of the SyncInterval policy. Defaults to one second.
+	SyncInterval time.Duration
+}
+
+// FileQueueService is a QueueService persisted in a directory.
+// Values are appended to numbered segment files and the read position is kept in an offset file,
+// so a reopened queue resumes where it stopped. Fully consumed segments are deleted.
+type FileQueueService struct {
+	mutex   sync.Mutex
+	dir     string
+	options FileQueueOptions
+	closed  bool
+	changed chan struct{}
+
+	writer       *os.File
+	writeSegment int
+	writeSize    int64
+
+	reader      *os.File
+	readSegment int
+	readPos     int64
+
+	lastSync    time.Time
+	dirty       bool
+	offsetDirty bool
+	syncErr     error
+	done        chan struct{}
+}
+
+// NewFileQueueService opens the queue stored in dir, creating it if needed.
+// A record left incomplete by a crash at the end of the last segment is discarded.
+func NewFileQueueService(dir string, options FileQueueOptions) (*FileQueueService, error) {
+	if options.SegmentSize <= 0 {
+		options.SegmentSize = 64 << 20
+	}
+	if options.SyncInterval <= 0 {
+		options.SyncInterval = time.Second
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	q := &FileQueueService{
+		dir:      dir,
+		options:  options,
+		changed:  make(chan struct{}),
+		lastSync: time.Now(),
+	}
+	if err := q.open(); err != nil {
+		q.closeFiles()
+		return nil, err
+	}
+	if options.Sync == SyncInterval {
+		q.done = make(chan struct{})
+		go q.syncEvery(options.SyncInterval)
+	}
+	return q, nil
+}
+
+// syncEvery flushes the queue every interval until it is closed.
+// A failure is returned by the next Push, Sync or Close.
+func (q *FileQueueService) syncEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-ticker.C:
+			q.mutex.Lock()
+			if err := q.sync(); err != nil && q.syncErr == nil {
+				q.syncErr = err
+			}
+			q.mutex.Unlock()
+		}
+	}
+}
+
+func (q *FileQueueService) open() error {
+	segments, err := q.segments()
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 {
+		segments = []int{1}
+	}
+
+	q.readSegment, q.readPos, err = q.loadOffset()
+	if err != nil {
+		return err
+	}
+	if q.readSegment < segments[0] {
+		q.readSegment, q.readPos = segments[0], 0
+	}
+	if q.readSegment > segments[len(segments)-1] {
+		return fmt.Errorf("offset points to missing segment %d", q.readSegment)
+	}
+
+	// Segments before the offset were consumed before a crash prevented their removal
+	for _, segment := range segments {
+		if segment < q.readSegment {
+			if err := os.Remove(q.segmentPath(segment)); err != nil {
+				return err
+			}
+		}
+	}
+
+	q.writeSegment = segments[len(segments)-1]
+	q.writer, err = os.OpenFile(q.segmentPath(q.writeSegment), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	q.writeSize, err = validLength(q.writer)
+	if err != nil {
+		return err
+	}
+	if err := q.writer.Truncate(q.writeSize); err != nil {
+		return err
+	}
+	if _, err := q.writer.Seek(q.writeSize, io.SeekStart); err != nil {
+		return err
+	}
+	if q.readSegment == q.writeSegment && q.readPos > q.writeSize {
+		q.readPos = q.writeSize
+	}
+
+	q.reader, err = os.Open(q.segmentPath(q.readSegment))
+	return err
+}
+
+func (q *FileQueueService) Push(ctx context.Context, value []byte) error {
+	return q.PushBatch(ctx, [][]byte{value})
+}
+
+// PushBatch appends the values in order and applies the sync policy once
+func (q *FileQueueService) PushBatch(ctx context.Context, values [][]byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	for _, value := range values {
+		if err := q.write(value); err != nil {
+			return err
+		}
+	}
+
+	if err := q.maybeSync(); err != nil {
+		return err
+	}
+	q.notify()
+	return nil
+}
+
+func (q *FileQueueService) Pop(ctx context.Context) ([]byte, error) {
+	values, err := q.PopBatch(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+// PopBatch waits for a value until one is pushed or ctx is done, then returns up to max values.
+// The read offset is persisted once for the whole batch.
+func (q *FileQueueService) PopBatch(ctx context.Context, max int) ([][]byte, error) {
+	if max <= 0 {
+		max = 1
+	}
+
+	for {
+		q.mutex.Lock()
+		if q.closed {
+			q.mutex.Unlock()
+			return nil, ErrQueueClosed
+		}
+
+		values, err := q.read(max)
+		if err != nil || len(values) > 0 {
+			q.mutex.Unlock()
+			return values, err
+		}
+		changed := q.changed
+		q.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Sync flushes pending writes and the read offset to disk
+func (q *FileQueueService) Sync() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	return q.sync()
+}
+
+// Close flushes and closes the queue files. Waiting Pop calls return ErrQueueClosed.
+func (q *FileQueueService) Close() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return nil
+	}
+	err := q.sync()
+	q.closed = true
+	if q.done != nil {
+		close(q.done)
+	}
+	q.notify()
+	if closeErr := q.closeFiles(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (q *FileQueueService) write(value []byte) error {
+	if q.writeSize > 0 && q.writeSize >= q.options.SegmentSize {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+
+	record := make([]byte, recordHeaderSize+len(value))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(value)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(value))
+	copy(record[recordHeaderSize:], value)
+	if _, err := q.writer.Write(record); err != nil {
+		return err
+	}
+	q.writeSize += int64(len(record))
+	q.dirty = true
+	return nil
+}
+
+// read returns up to max records from the read offset, moving to the next segment when the current one is consumed.
+// It returns no value when the queue is empty.
+func (q *FileQueueService) read(max int) ([][]byte, error) {
+	values := make([][]byte, 0, max)
+	for len(values) < max {
+		value, err := q.readRecord()
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			break
+		}
+		values = append(values, value)
+	}
+	if len(values) == 0 {
+		return values, nil
+	}
+
+	if q.options.Sync == SyncInterval {
+		q.offsetDirty = true
+		return values, nil
+	}
+	if err := q.storeOffset(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func (q *FileQueueService) readRecord() ([]byte, error) {
+	for {
+		header := make([]byte, recordHeaderSize)
+		_, err := q.reader.ReadAt(header, q.readPos)
+		if errors.Is(err, io.EOF) {
+			if q.readSegment == q.writeSegment {
+				return nil, nil
+			}
+			if err := q.advance(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		value := make([]byte, binary.BigEndian.Uint32(header[0:4]))
+		if _, err := q.reader.ReadAt(value, q.readPos+recordHeaderSize); err != nil {
+			return nil, fmt.Errorf("could not read record at %d in segment %d : %w", q.readPos, q.readSegment, err)
+		}
+		if crc32.ChecksumIEEE(value) != binary.BigEndian.Uint32(header[4:8]) {
+			return nil, fmt.Errorf("corrupted record at %d in segment %d", q.readPos, q.readSegment)
+		}
+
+		q.readPos += int64(recordHeaderSize + len(value))
+		return value, nil
+	}
+}
+
+// advance moves the read offset to the next segment and deletes the consumed one
+func (q *FileQueueService) advance() error {
+	consumed := q.readSegment
+	reader, err := os.Open(q.segmentPath(consumed + 1))
+	if err != nil {
+		return err
+	}
+	q.reader.Close()
+	q.reader = reader
+	q.readSegment, q.readPos = consumed+1, 0
+
+	if err := q.storeOffset(); err != nil {
+		return err
+	}
+	return os.Remove(q.segmentPath(consumed))
+}
+
+func (q *FileQueueService) rotate() error {
+	if err := q.writer.Sync(); err != nil {
+		return err
+	}
+	if err := q.writer.Close(); err != nil {
+		return err
+	}
+
+	writer, err := os.OpenFile(q.segmentPath(q.writeSegment+1), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if q.options.Sync != SyncNever {
+		if err := syncDir(q.dir); err != nil {
+			writer.Close()
+			return err
+		}
+	}
+	q.writer = writer
+	q.writeSegment++
+	q.writeSize = 0
+	return nil
+}
+
+// storeOffset replaces the offset file. Unless the policy is SyncNever, the file and its directory are flushed
+// so that the new offset survives a power failure.
+func (q *FileQueueService) storeOffset() error {
+	content := make([]byte, 16)
+	binary.BigEndian.PutUint64(content[0:8], uint64(q.readSegment))
+	binary.BigEndian.PutUint64(content[8:16], uint64(q.readPos))
+
+	tmp := filepath.Join(q.dir, offsetFileName+".tmp")
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if q.options.Sync != SyncNever {
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(q.dir, offsetFileName)); err != nil {
+		return err
+	}
+	q.offsetDirty = false
+	if q.options.Sync != SyncNever {
+		return syncDir(q.dir)
+	}
+	return nil
+}
+
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
+
+func (q *FileQueueService) loadOffset() (int, int64, error) {
+	content, err := os.ReadFile(filepath.Join(q.dir, offsetFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(content) != 16 {
+		return 0, 0, fmt.Errorf("invalid offset file of %d bytes", len(content))
+	}
+	return int(binary.BigEndian.Uint64(content[0:8])), int64(binary.BigEndian.Uint64(content[8:16])), nil
+}
+
+func (q *FileQueueService) maybeSync() error {
+	switch q.options.Sync {
+	case SyncAlways:
+		return q.sync()
+	case SyncInterval:
+		if q.syncErr != nil || time.Since(q.lastSync) >= q.options.SyncInterval {
+			return q.sync()
+		}
+	}
+	return nil
+}
+
+// sync flushes pending writes and stores the read offset if it changed since the last flush
+func (q *FileQueueService) sync() error {
+	if err := q.syncErr; err != nil {
+		q.syncErr = nil
+		return err
+	}
+	if q.dirty {
+		if err := q.writer.Sync(); err != nil {
+			return err
+		}
+		q.dirty = false
+	}
+	if q.offsetDirty {
+		if err := q.storeOffset(); err != nil {
+			return err
+		}
+	}
+	q.lastSync = time.Now()
+	return nil
+}
+
+func (q *FileQueueService) closeFiles() error {
+	var err error
+	if q.writer != nil {
+		err = q.writer.Close()
+	}
+	if q.reader != nil {
+		if closeErr := q.reader.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// notify wakes up the waiting Pop calls. The caller must hold the lock.
+func (q *FileQueueService) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+func (q *FileQueueService) segments() ([]int, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, segmentExtension) {
+			continue
+		}
+		segment, err := strconv.Atoi(strings.TrimSuffix(name, segmentExtension))
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	sort.Ints(segments)
+	return segments, nil
+}
+
+func (q *FileQueueService) segmentPath(segment int) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", segment, segmentExtension))
+}
+
+// validLength returns the length of the leading complete and uncorrupted records of file
+func validLength(file *os.File) (int64, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+
+	var pos int64
+	header := make([]byte, recordHeaderSize)
+	for pos+recordHeaderSize <= size {
+		if _, err := file.ReadAt(header, pos); err != nil {
+			return 0, err
+		}
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		if pos+recordHeaderSize+length > size {
+			break
+		}
+		value := make([]byte, length)
+		if _, err := file.ReadAt(value, pos+recordHeaderSize); err != nil {
+			return 0, err
+		}
+		if crc32.ChecksumIEEE(value) != binary.BigEndian.Uint32(header[4:8]) {
+			break
+		}
+		pos += recordHeaderSize + length
+	}
+	return pos, nil
+}
@@ -0,0 +1,180 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/owlint/goddd/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func pushValues(t *testing.T, queue services.QueueService, from, to int) {
+	for i := from; i < to; i++ {
+		assert.NoError(t, queue.Push(context.Background(), []byte(fmt.Sprintf("value-%d", i))))
+	}
+}
+
+func assertPopped(t *testing.T, queue services.QueueService, from, to int) {
+	for i := from; i < to; i++ {
+		res, err := queue.Pop(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("value-%d", i), string(res))
+	}
+}
+
+func segmentFiles(t *testing.T, dir string) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	assert.NoError(t, err)
+	return files
+}
+
+func TestFileQueuePushPop(t *testing.T) {
+	queue, err := services.NewFileQueueService(t.TempDir(), services.FileQueueOptions{})
+	assert.NoError(t, err)
+	defer queue.Close()
+
+	pushValues(t, queue, 0, 3)
+	assertPopped(t, queue, 0, 3)
+}
+
+func TestFileQueueReopened(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := services.NewFileQueueService(dir, services.FileQueueOptions{})
+	assert.NoError(t, err)
+	pushValues(t, queue, 0, 5)
+	assertPopped(t, queue, 0, 2)
+	assert.NoError(t, queue.Close())
+
+	queue, err = services.NewFileQueueService(dir, services.FileQueueOptions{})
+	assert.NoError(t, err)
+	defer queue.Close()
+	pushValues(t, queue, 5, 7)
+	assertPopped(t, queue, 2, 7)
+}
+
+func TestFileQueueKilledMidStream(t *testing.T) {
+	dir := t.TempDir()
+	options := services.FileQueueOptions{SegmentSize: 64}
+	queue, err := services.NewFileQueueService(dir, options)
+	assert.NoError(t, err)
+	pushValues(t, queue, 0, 10)
+	assertPopped(t, queue, 0, 4)
+
+	// The process dies without closing the queue, in the middle of writing a record
+	files := segmentFiles(t, dir)
+	last, err := os.OpenFile(files[len(files)-1], os.O_APPEND|os.O_WRONLY, 0o644)
+	assert.NoError(t, err)
+	_, err = last.Write([]byte{0, 0, 0, 42, 1, 2})
+	assert.NoError(t, err)
+	last.Close()
+
+	queue, err = services.NewFileQueueService(dir, options)
+	assert.NoError(t, err)
+	defer queue.Close()
+	pushValues(t, queue, 10, 12)
+	assertPopped(t, queue, 4, 12)
+}
+
+func TestFileQueueCompaction(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := services.NewFileQueueService(dir, services.FileQueueOptions{SegmentSize: 32})
+	assert.NoError(t, err)
+	defer queue.Close()
+
+	pushValues(t, queue, 0, 10)
+	assert.Len(t, segmentFiles(t, dir), 4)
+
+	assertPopped(t, queue, 0, 10)
+	assert.Len(t, segmentFiles(t, dir), 1)
+}
+
+func TestFileQueueSyncPolicies(t *testing.T) {
+	for _, policy := range []services.SyncPolicy{services.SyncAlways, services.SyncInterval, services.SyncNever} {
+		dir := t.TempDir()
+		options := services.FileQueueOptions{Sync: policy, SyncInterval: time.Millisecond}
+		queue, err := services.NewFileQueueService(dir, options)
+		assert.NoError(t, err)
+		t.Cleanup(func() { queue.Close() })
+		pushValues(t, queue, 0, 3)
+		assert.NoError(t, queue.Sync())
+
+		reopened, err := services.NewFileQueueService(dir, options)
+		assert.NoError(t, err)
+		t.Cleanup(func() { reopened.Close() })
+		assertPopped(t, reopened, 0, 3)
+	}
+}
+
+func TestFileQueueSyncIntervalInBackground(t *testing.T) {
+	dir := t.TempDir()
+	options := services.FileQueueOptions{Sync: services.SyncInterval, SyncInterval: 20 * time.Millisecond}
+	queue, err := services.NewFileQueueService(dir, options)
+	assert.NoError(t, err)
+	defer queue.Close()
+	pushValues(t, queue, 0, 3)
+	assertPopped(t, queue, 0, 2)
+
+	// The process dies without closing the queue once the interval elapsed
+	time.Sleep(100 * time.Millisecond)
+
+	reopened, err := services.NewFileQueueService(dir, options)
+	assert.NoError(t, err)
+	defer reopened.Close()
+	assertPopped(t, reopened, 2, 3)
+}
+
+func TestFileQueuePopWaits(t *testing.T) {
+	queue, err := services.NewFileQueueService(t.TempDir(), services.FileQueueOptions{})
+	assert.NoError(t, err)
+	defer queue.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		queue.Push(context.Background(), []byte("Hello"))
+	}()
+	res, err := queue.Pop(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Hello"), res)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = queue.Pop(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestFileQueueClosed(t *testing.T) {
+	queue, err := services.NewFileQueueService(t.TempDir(), services.FileQueueOptions{})
+	assert.NoError(t, err)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		queue.Close()
+	}()
+	_, err = queue.Pop(context.Background())
+	assert.ErrorIs(t, err, services.ErrQueueClosed)
+	assert.ErrorIs(t, queue.Push(context.Background(), []byte("Hello")), services.ErrQueueClosed)
+}
+
+func TestFileQueueBatch(t *testing.T) {
+	dir := t.TempDir()
+	queue, err := services.NewFileQueueService(dir, services.FileQueueOptions{SegmentSize: 16})
+	assert.NoError(t, err)
+	values := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+
+	assert.NoError(t, queue.PushBatch(context.Background(), values))
+	res, err := queue.PopBatch(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, values[:2], res)
+	assert.NoError(t, queue.Close())
+
+	queue, err = services.NewFileQueueService(dir, services.FileQueueOptions{SegmentSize: 16})
+	assert.NoError(t, err)
+	defer queue.Close()
+	res, err = queue.PopBatch(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, values[2:], res)
+}
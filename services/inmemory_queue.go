@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// InMemoryQueueService is a QueueService keeping values in process memory.
+// Push waits for room when the queue is full and Pop waits for a value when it is empty.
+type InMemoryQueueService struct {
+	mutex    sync.Mutex
+	values   [][]byte
+	capacity int
+	changed  chan struct{}
+}
+
+// InMemoryQueueBroker gives access to in-memory queues by name
+type InMemoryQueueBroker struct {
+	mutex    sync.Mutex
+	capacity int
+	queues   map[string]*InMemoryQueueService
+}
+
+// NewInMemoryQueueService creates a queue holding at most capacity values, unbounded if capacity is 0
+func NewInMemoryQueueService(capacity int) *InMemoryQueueService {
+	return &InMemoryQueueService{
+		values:   make([][]byte, 0),
+		capacity: capacity,
+		changed:  make(chan struct{}),
+	}
+}
+
+// NewInMemoryQueueBroker creates a broker whose queues hold at most capacity values, unbounded if capacity is 0
+func NewInMemoryQueueBroker(capacity int) *InMemoryQueueBroker {
+	return &InMemoryQueueBroker{
+		capacity: capacity,
+		queues:   make(map[string]*InMemoryQueueService),
+	}
+}
+
+// Queue returns the queue with the given name, creating it on first use
+func (b *InMemoryQueueBroker) Queue(name string) QueueService {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	queue, ok := b.queues[name]
+	if !ok {
+		queue = NewInMemoryQueueService(b.capacity)
+		b.queues[name] = queue
+	}
+	return queue
+}
+
+func (q *InMemoryQueueService) Push(ctx context.Context, value []byte) error {
+	for {
+		q.mutex.Lock()
+		if q.capacity <= 0 || len(q.values) < q.capacity {
+			q.values = append(q.values, append([]byte(nil), value...))
+			q.notify()
+			q.mutex.Unlock()
+			return nil
+		}
+		changed := q.changed
+		q.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (q *InMemoryQueueService) Pop(ctx context.Context) ([]byte, error) {
+	values, err := q.PopBatch(ctx, 1)
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+// PushBatch pushes the values in order, waiting for room as Push does
+func (q *InMemoryQueueService) PushBatch(ctx context.Context, values [][]byte) error {
+	for _, value := range values {
+		if err := q.Push(ctx, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PopBatch waits for a value and returns up to max queued values
+func (q *InMemoryQueueService) PopBatch(ctx context.Context, max int) ([][]byte, error) {
+	if max <= 0 {
+		max = 1
+	}
+
+	for {
+		q.mutex.Lock()
+		if len(q.values) > 0 {
+			count := max
+			if count > len(q.values) {
+				count = len(q.values)
+			}
+			values := make([][]byte, count)
+			copy(values, q.values[:count])
+			for i := 0; i < count; i++ {
+				q.values[i] = nil
+			}
+			q.values = q.values[count:]
+			q.notify()
+			q.mutex.Unlock()
+			return values, nil
+		}
+		changed := q.changed
+		q.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Len returns the number of queued values
+func (q *InMemoryQueueService) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	return len(q.values)
+}
+
+// notify wakes up the waiting Push and Pop calls. The caller must hold the lock.
+func (q *InMemoryQueueService) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
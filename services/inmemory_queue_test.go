@@ -0,0 +1,96 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/owlint/goddd/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryQueuePushPop(t *testing.T) {
+	queue := services.NewInMemoryQueueService(0)
+	hello := []byte("Hello")
+	world := []byte("world")
+
+	assert.NoError(t, queue.Push(context.Background(), hello))
+	assert.NoError(t, queue.Push(context.Background(), world))
+	assert.Equal(t, 2, queue.Len())
+
+	res, err := queue.Pop(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, hello, res)
+	res, err = queue.Pop(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, world, res)
+}
+
+func TestInMemoryQueuePopWaits(t *testing.T) {
+	queue := services.NewInMemoryQueueService(0)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		queue.Push(context.Background(), []byte("Hello"))
+	}()
+	res, err := queue.Pop(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Hello"), res)
+}
+
+func TestInMemoryQueuePopCancelled(t *testing.T) {
+	queue := services.NewInMemoryQueueService(0)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := queue.Pop(ctx)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestInMemoryQueueCapacity(t *testing.T) {
+	queue := services.NewInMemoryQueueService(1)
+	assert.NoError(t, queue.Push(context.Background(), []byte("Hello")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := queue.Push(ctx, []byte("world"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		queue.Pop(context.Background())
+	}()
+	err = queue.Push(context.Background(), []byte("world"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, queue.Len())
+}
+
+func TestInMemoryQueueBroker(t *testing.T) {
+	broker := services.NewInMemoryQueueBroker(0)
+
+	assert.NoError(t, broker.Queue("billing").Push(context.Background(), []byte("Hello")))
+	assert.NoError(t, broker.Queue("audit").Push(context.Background(), []byte("world")))
+
+	res, err := broker.Queue("billing").Pop(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Hello"), res)
+	res, err = broker.Queue("audit").Pop(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("world"), res)
+}
+
+func TestInMemoryQueueBatch(t *testing.T) {
+	queue := services.NewInMemoryQueueService(0)
+	values := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+
+	assert.NoError(t, queue.PushBatch(context.Background(), values))
+
+	res, err := queue.PopBatch(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, values[:2], res)
+	res, err = queue.PopBatch(context.Background(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, values[2:], res)
+}
@@ -4,15 +4,28 @@ package services
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/go-redis/redis/v9"
 )
 
+// blockTimeout bounds blocking reads so that context cancellation is noticed
+const blockTimeout = time.Second
+
 type QueueService interface {
 	Push(context.Context, []byte) error
 	Pop(context.Context) ([]byte, error)
 }
 
+// BatchQueueService is a QueueService able to move several values in one round trip
+type BatchQueueService interface {
+	QueueService
+	PushBatch(context.Context, [][]byte) error
+	// PopBatch waits for at least one value and returns up to max values
+	PopBatch(context.Context, int) ([][]byte, error)
+}
+
 type RedisQueueService struct {
 	client    *redis.Client
 	queueName string
@@ -29,11 +42,74 @@ func (r *RedisQueueService) Push(ctx context.Context, value []byte) error {
 	return r.client.LPush(ctx, r.queueName, value).Err()
 }
 
+// Pop waits for a value until one is pushed or ctx is done
 func (r *RedisQueueService) Pop(ctx context.Context) ([]byte, error) {
-	values, err := r.client.BRPop(ctx, 0, r.queueName).Result()
-	if err != nil {
-		return nil, err
+	for {
+		values, err := r.client.BRPop(ctx, blockTimeout, r.queueName).Result()
+		if errors.Is(err, redis.Nil) {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte(values[1]), nil
+	}
+}
+
+// PushBatch pushes all the values with a single command, preserving their order
+func (r *RedisQueueService) PushBatch(ctx context.Context, values [][]byte) error {
+	if len(values) == 0 {
+		return nil
 	}
 
-	return []byte(values[1]), nil
+	args := make([]interface{}, len(values))
+	for i, value := range values {
+		args[i] = value
+	}
+	return r.client.LPush(ctx, r.queueName, args...).Err()
+}
+
+// PopBatch takes up to max values at once, waiting for one if the queue is empty.
+// The blocking pop and the pop of the remaining values are pipelined in a single round trip: when the blocking
+// pop times out, the values pushed meanwhile may still be taken by the second one and are returned.
+func (r *RedisQueueService) PopBatch(ctx context.Context, max int) ([][]byte, error) {
+	if max <= 0 {
+		max = 1
+	}
+
+	for {
+		var first *redis.StringSliceCmd
+		var rest *redis.StringSliceCmd
+		_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			first = pipe.BRPop(ctx, blockTimeout, r.queueName)
+			if max > 1 {
+				rest = pipe.RPopCount(ctx, r.queueName, max-1)
+			}
+			return nil
+		})
+
+		values := make([][]byte, 0, max)
+		if popped, popErr := first.Result(); popErr == nil {
+			values = append(values, []byte(popped[1]))
+		}
+		if rest != nil {
+			for _, value := range rest.Val() {
+				values = append(values, []byte(value))
+			}
+		}
+		if len(values) > 0 {
+			return values, nil
+		}
+
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
 }
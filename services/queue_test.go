@@ -45,3 +45,21 @@ func TestRedisQueuePushPopMultiple(t *testing.T) {
 		assert.Equal(t, world, res)
 	})
 }
+
+func TestRedisQueueBatch(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := services.NewRedisQueueService(conn, "test").(services.BatchQueueService)
+		values := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+
+		err := queue.PushBatch(context.Background(), values)
+		assert.NoError(t, err)
+
+		res, err := queue.PopBatch(context.Background(), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, values[:2], res)
+
+		res, err = queue.PopBatch(context.Background(), 2)
+		assert.NoError(t, err)
+		assert.Equal(t, values[2:], res)
+	})
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+)
+
+// RedisInbox records processed event IDs as Redis keys expiring after a TTL
+type RedisInbox struct {
+	client *redis.Client
+	name   string
+	ttl    time.Duration
+}
+
+// NewRedisInbox creates an inbox whose keys are prefixed by name.
+// Processed event IDs are forgotten after ttl, never if ttl is 0.
+func NewRedisInbox(client *redis.Client, name string, ttl time.Duration) *RedisInbox {
+	return &RedisInbox{
+		client: client,
+		name:   name,
+		ttl:    ttl,
+	}
+}
+
+func (i *RedisInbox) key(eventID string) string {
+	return i.name + ":" + eventID
+}
+
+func (i *RedisInbox) Processed(ctx context.Context, eventID string) (bool, error) {
+	count, err := i.client.Exists(ctx, i.key(eventID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (i *RedisInbox) MarkProcessed(ctx context.Context, eventID string) error {
+	return i.client.Set(ctx, i.key(eventID), time.Now().Unix(), i.ttl).Err()
+}
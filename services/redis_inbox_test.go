@@ -0,0 +1,32 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/owlint/goddd/services"
+	"github.com/owlint/goddd/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisInbox(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		inbox := services.NewRedisInbox(conn, "inbox", time.Hour)
+
+		processed, err := inbox.Processed(context.Background(), "event")
+		assert.NoError(t, err)
+		assert.False(t, processed)
+
+		assert.NoError(t, inbox.MarkProcessed(context.Background(), "event"))
+
+		processed, err = inbox.Processed(context.Background(), "event")
+		assert.NoError(t, err)
+		assert.True(t, processed)
+
+		ttl, err := conn.TTL(context.Background(), "inbox:event").Result()
+		assert.NoError(t, err)
+		assert.InDelta(t, time.Hour, ttl, float64(time.Minute))
+	})
+}
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/google/uuid"
+)
+
+// Message is a queued value whose processing must be acknowledged
+type Message struct {
+	ID         string
+	Body       []byte
+	Deliveries int
+}
+
+// AckQueueService is a QueueService keeping received messages until they are acknowledged
+type AckQueueService interface {
+	QueueService
+	Receive(context.Context) (Message, error)
+	Ack(context.Context, Message) error
+	Nack(context.Context, Message) error
+}
+
+// ReliableQueueOptions configures a RedisReliableQueueService
+type ReliableQueueOptions struct {
+	// VisibilityTimeout is how long a received message may stay unacknowledged before being requeued
+	VisibilityTimeout time.Duration
+	// MaxDeliveries moves a message to the dead letter list once received more times. Zero means unlimited.
+	MaxDeliveries int
+}
+
+var ErrMessageNotProcessing = errors.New("message is no longer processing, its visibility timeout expired")
+var ErrMissingMessageBody = errors.New("message body is missing")
+
+// messageIDPrefix marks the IDs pushed by a RedisReliableQueueService, whose body is stored apart.
+// Other values, pushed by a plain RedisQueueService, are received as their own body. Neither protobuf
+// messages nor envelopes start with two zero bytes.
+const messageIDPrefix = "\x00\x00msg:"
+
+// RedisReliableQueueService is a Redis list queue where received messages are
+// moved into a processing list until they are acknowledged.
+type RedisReliableQueueService struct {
+	client    *redis.Client
+	queueName string
+	options   ReliableQueueOptions
+}
+
+// requeueScript moves the processing messages whose visibility timeout expired back to the queue.
+// Processing messages without deadline (consumer crashed right after receiving) are given one.
+var requeueScript = redis.NewScript(`
+local deadlines, processing, queue = KEYS[1], KEYS[2], KEYS[3]
+local now, timeout = tonumber(ARGV[1]), tonumber(ARGV[2])
+
+for _, id in ipairs(redis.call('LRANGE', processing, 0, -1)) do
+	if not redis.call('ZSCORE', deadlines, id) then
+		redis.call('ZADD', deadlines, now + timeout, id)
+	end
+end
+
+local requeued = 0
+for _, id in ipairs(redis.call('ZRANGEBYSCORE', deadlines, '-inf', now)) do
+	redis.call('ZREM', deadlines, id)
+	if redis.call('LREM', processing, 1, id) > 0 then
+		redis.call('RPUSH', queue, id)
+		requeued = requeued + 1
+	end
+end
+return requeued
+`)
+
+// forgetScript removes a message still in the processing list, moving its body to the dead letter list
+// when one is given. A message requeued meanwhile is left untouched and 0 is returned.
+var forgetScript = redis.NewScript(`
+local processing, deadlines, messages, deliveries, dead = KEYS[1], KEYS[2], KEYS[3], KEYS[4], KEYS[5]
+local id = ARGV[1]
+
+if redis.call('LREM', processing, 1, id) == 0 then
+	return 0
+end
+redis.call('ZREM', deadlines, id)
+redis.call('HDEL', messages, id)
+redis.call('HDEL', deliveries, id)
+if ARGV[2] == '1' then
+	redis.call('LPUSH', dead, ARGV[3])
+end
+return 1
+`)
+
+// nackScript puts a message still in the processing list back at the end of the queue
+var nackScript = redis.NewScript(`
+local processing, deadlines, queue = KEYS[1], KEYS[2], KEYS[3]
+local id = ARGV[1]
+
+if redis.call('LREM', processing, 1, id) == 0 then
+	return 0
+end
+redis.call('ZREM', deadlines, id)
+redis.call('LPUSH', queue, id)
+return 1
+`)
+
+func NewRedisReliableQueueService(client *redis.Client, queueName string, options ReliableQueueOptions) *RedisReliableQueueService {
+	if options.VisibilityTimeout <= 0 {
+		options.VisibilityTimeout = 30 * time.Second
+	}
+
+	return &RedisReliableQueueService{
+		client:    client,
+		queueName: queueName,
+		options:   options,
+	}
+}
+
+func (r *RedisReliableQueueService) processingKey() string {
+	return r.queueName + ":processing"
+}
+
+func (r *RedisReliableQueueService) deadlinesKey() string {
+	return r.queueName + ":deadlines"
+}
+
+func (r *RedisReliableQueueService) messagesKey() string {
+	return r.queueName + ":messages"
+}
+
+func (r *RedisReliableQueueService) deliveriesKey() string {
+	return r.queueName + ":deliveries"
+}
+
+func (r *RedisReliableQueueService) deadLettersKey() string {
+	return r.queueName + ":dead"
+}
+
+func (r *RedisReliableQueueService) Push(ctx context.Context, value []byte) error {
+	id := messageIDPrefix + uuid.NewString()
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, r.messagesKey(), id, value)
+		pipe.LPush(ctx, r.queueName, id)
+		return nil
+	})
+	return err
+}
+
+// Pop receives a message and acknowledges it straight away
+func (r *RedisReliableQueueService) Pop(ctx context.Context) ([]byte, error) {
+	message, err := r.Receive(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return message.Body, r.Ack(ctx, message)
+}
+
+// Receive waits for a message and moves it to the processing list.
+// Messages received more than MaxDeliveries times are moved to the dead letter list instead.
+func (r *RedisReliableQueueService) Receive(ctx context.Context) (Message, error) {
+	for {
+		id, err := r.client.BLMove(ctx, r.queueName, r.processingKey(), "RIGHT", "LEFT", blockTimeout).Result()
+		if errors.Is(err, redis.Nil) {
+			if ctx.Err() != nil {
+				return Message{}, ctx.Err()
+			}
+			continue
+		}
+		if err != nil {
+			return Message{}, err
+		}
+
+		deadline := time.Now().Add(r.options.VisibilityTimeout).UnixMilli()
+		var deliveries *redis.IntCmd
+		var body *redis.StringCmd
+		_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.ZAdd(ctx, r.deadlinesKey(), redis.Z{Score: float64(deadline), Member: id})
+			deliveries = pipe.HIncrBy(ctx, r.deliveriesKey(), id, 1)
+			body = pipe.HGet(ctx, r.messagesKey(), id)
+			return nil
+		})
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return Message{}, err
+		}
+
+		message := Message{
+			ID:         id,
+			Body:       []byte(id),
+			Deliveries: int(deliveries.Val()),
+		}
+		if strings.HasPrefix(id, messageIDPrefix) {
+			if body.Err() != nil {
+				if _, err := r.forget(ctx, message, false); err != nil {
+					return Message{}, err
+				}
+				return Message{}, fmt.Errorf("%w : %s", ErrMissingMessageBody, id)
+			}
+			message.Body = []byte(body.Val())
+		}
+
+		if r.options.MaxDeliveries > 0 && message.Deliveries > r.options.MaxDeliveries {
+			if err := r.deadLetter(ctx, message); err != nil {
+				return Message{}, err
+			}
+			continue
+		}
+
+		return message, nil
+	}
+}
+
+// Ack removes a processed message from the queue.
+// It returns ErrMessageNotProcessing when the message was requeued meanwhile, it will then be delivered again.
+func (r *RedisReliableQueueService) Ack(ctx context.Context, message Message) error {
+	forgotten, err := r.forget(ctx, message, false)
+	if err != nil {
+		return err
+	}
+	if !forgotten {
+		return fmt.Errorf("%w : %s", ErrMessageNotProcessing, message.ID)
+	}
+	return nil
+}
+
+// Nack puts a message back at the end of the queue.
+// It returns ErrMessageNotProcessing when the message was already requeued.
+func (r *RedisReliableQueueService) Nack(ctx context.Context, message Message) error {
+	requeued, err := nackScript.Run(
+		ctx,
+		r.client,
+		[]string{r.processingKey(), r.deadlinesKey(), r.queueName},
+		message.ID,
+	).Int()
+	if err != nil {
+		return err
+	}
+	if requeued == 0 {
+		return fmt.Errorf("%w : %s", ErrMessageNotProcessing, message.ID)
+	}
+	return nil
+}
+
+// Requeue moves the messages whose visibility timeout expired back to the queue.
+// It returns the number of requeued messages.
+func (r *RedisReliableQueueService) Requeue(ctx context.Context) (int, error) {
+	keys := []string{r.deadlinesKey(), r.processingKey(), r.queueName}
+	requeued, err := requeueScript.Run(
+		ctx,
+		r.client,
+		keys,
+		time.Now().UnixMilli(),
+		r.options.VisibilityTimeout.Milliseconds(),
+	).Int()
+	if err != nil {
+		return 0, fmt.Errorf("could not requeue expired messages of %s : %w", r.queueName, err)
+	}
+	return requeued, nil
+}
+
+// RunReaper calls Requeue every interval until ctx is done
+func (r *RedisReliableQueueService) RunReaper(ctx context.Context, interval time.Duration, errChan chan<- error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Requeue(ctx); err != nil && ctx.Err() == nil {
+				errChan <- err
+			}
+		}
+	}
+}
+
+// DeadLetters returns the bodies of the messages that exceeded MaxDeliveries, oldest first
+func (r *RedisReliableQueueService) DeadLetters(ctx context.Context) ([][]byte, error) {
+	values, err := r.client.LRange(ctx, r.deadLettersKey(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([][]byte, len(values))
+	for i, value := range values {
+		letters[len(values)-1-i] = []byte(value)
+	}
+	return letters, nil
+}
+
+func (r *RedisReliableQueueService) deadLetter(ctx context.Context, message Message) error {
+	_, err := r.forget(ctx, message, true)
+	return err
+}
+
+// forget removes a message if it is still processing, and tells whether it was
+func (r *RedisReliableQueueService) forget(ctx context.Context, message Message, deadLetter bool) (bool, error) {
+	keys := []string{r.processingKey(), r.deadlinesKey(), r.messagesKey(), r.deliveriesKey(), r.deadLettersKey()}
+	flag := "0"
+	if deadLetter {
+		flag = "1"
+	}
+	forgotten, err := forgetScript.Run(ctx, r.client, keys, message.ID, flag, message.Body).Int()
+	return forgotten == 1, err
+}
@@ -0,0 +1,166 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/owlint/goddd/services"
+	"github.com/owlint/goddd/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReliableQueueReceiveAck(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := services.NewRedisReliableQueueService(conn, "test", services.ReliableQueueOptions{})
+		value := []byte("Hello")
+
+		err := queue.Push(context.Background(), value)
+		assert.NoError(t, err)
+
+		message, err := queue.Receive(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, value, message.Body)
+		assert.Equal(t, 1, message.Deliveries)
+		assert.EqualValues(t, 1, conn.LLen(context.Background(), "test:processing").Val())
+
+		err = queue.Ack(context.Background(), message)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, conn.LLen(context.Background(), "test:processing").Val())
+		assert.EqualValues(t, 0, conn.HLen(context.Background(), "test:messages").Val())
+	})
+}
+
+func TestReliableQueueNack(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := services.NewRedisReliableQueueService(conn, "test", services.ReliableQueueOptions{})
+		value := []byte("Hello")
+
+		err := queue.Push(context.Background(), value)
+		assert.NoError(t, err)
+		message, err := queue.Receive(context.Background())
+		assert.NoError(t, err)
+
+		err = queue.Nack(context.Background(), message)
+		assert.NoError(t, err)
+
+		redelivered, err := queue.Receive(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, message.ID, redelivered.ID)
+		assert.Equal(t, value, redelivered.Body)
+		assert.Equal(t, 2, redelivered.Deliveries)
+	})
+}
+
+func TestReliableQueueRequeueExpired(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := services.NewRedisReliableQueueService(conn, "test", services.ReliableQueueOptions{
+			VisibilityTimeout: 50 * time.Millisecond,
+		})
+
+		err := queue.Push(context.Background(), []byte("Hello"))
+		assert.NoError(t, err)
+		message, err := queue.Receive(context.Background())
+		assert.NoError(t, err)
+
+		requeued, err := queue.Requeue(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, requeued)
+
+		time.Sleep(100 * time.Millisecond)
+		requeued, err = queue.Requeue(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, requeued)
+
+		redelivered, err := queue.Receive(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, message.ID, redelivered.ID)
+		assert.Equal(t, 2, redelivered.Deliveries)
+	})
+}
+
+func TestReliableQueueDeadLetter(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := services.NewRedisReliableQueueService(conn, "test", services.ReliableQueueOptions{
+			MaxDeliveries: 2,
+		})
+
+		err := queue.Push(context.Background(), []byte("poison"))
+		assert.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			message, err := queue.Receive(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("poison"), message.Body)
+			assert.NoError(t, queue.Nack(context.Background(), message))
+		}
+
+		err = queue.Push(context.Background(), []byte("Hello"))
+		assert.NoError(t, err)
+		message, err := queue.Receive(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("Hello"), message.Body)
+
+		letters, err := queue.DeadLetters(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("poison")}, letters)
+	})
+}
+
+func TestReliableQueuePlainProducer(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		producer := services.NewRedisQueueService(conn, "test")
+		queue := services.NewRedisReliableQueueService(conn, "test", services.ReliableQueueOptions{})
+
+		err := producer.Push(context.Background(), []byte("Hello"))
+		assert.NoError(t, err)
+
+		res, err := queue.Pop(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("Hello"), res)
+	})
+}
+
+func TestReliableQueueLateAck(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := services.NewRedisReliableQueueService(conn, "test", services.ReliableQueueOptions{
+			VisibilityTimeout: 50 * time.Millisecond,
+		})
+
+		err := queue.Push(context.Background(), []byte("Hello"))
+		assert.NoError(t, err)
+		message, err := queue.Receive(context.Background())
+		assert.NoError(t, err)
+
+		time.Sleep(100 * time.Millisecond)
+		requeued, err := queue.Requeue(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, requeued)
+
+		err = queue.Ack(context.Background(), message)
+		assert.ErrorIs(t, err, services.ErrMessageNotProcessing)
+		err = queue.Nack(context.Background(), message)
+		assert.ErrorIs(t, err, services.ErrMessageNotProcessing)
+
+		redelivered, err := queue.Receive(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, message.ID, redelivered.ID)
+		assert.Equal(t, []byte("Hello"), redelivered.Body)
+		assert.EqualValues(t, 0, conn.LLen(context.Background(), "test").Val())
+	})
+}
+
+func TestReliableQueueMissingBody(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := services.NewRedisReliableQueueService(conn, "test", services.ReliableQueueOptions{})
+
+		err := queue.Push(context.Background(), []byte("Hello"))
+		assert.NoError(t, err)
+		conn.Del(context.Background(), "test:messages")
+
+		_, err = queue.Receive(context.Background())
+		assert.ErrorIs(t, err, services.ErrMissingMessageBody)
+		assert.EqualValues(t, 0, conn.LLen(context.Background(), "test:processing").Val())
+	})
+}
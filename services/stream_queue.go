@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/google/uuid"
+)
+
+const streamBodyField = "body"
+const streamDeliveriesField = "deliveries"
+
+// StreamQueueOptions configures a RedisStreamQueueService
+type StreamQueueOptions struct {
+	// Group is the consumer group sharing the stream messages
+	Group string
+	// Consumer identifies this replica in the group, a unique name is generated if empty
+	Consumer string
+	// ClaimAfter is how long a message may stay pending before another consumer claims it.
+	// It defaults to 30 seconds, a negative value disables pending entries recovery.
+	ClaimAfter time.Duration
+	// Block is the longest a single read waits for new messages
+	Block time.Duration
+	// MaxLen trims the stream to approximately this many entries. Zero disables it.
+	MaxLen int64
+	// MaxAge trims the entries older than this duration. Zero disables it.
+	MaxAge time.Duration
+}
+
+// RedisStreamQueueService is a QueueService on a Redis stream read through a consumer group.
+// Every message is delivered to a single consumer of the group and stays pending until acknowledged.
+type RedisStreamQueueService struct {
+	client  *redis.Client
+	stream  string
+	options StreamQueueOptions
+}
+
+func NewRedisStreamQueueService(client *redis.Client, stream string, options StreamQueueOptions) *RedisStreamQueueService {
+	if options.Group == "" {
+		options.Group = stream
+	}
+	if options.Consumer == "" {
+		hostname, _ := os.Hostname()
+		options.Consumer = fmt.Sprintf("%s-%s", hostname, uuid.NewString())
+	}
+	if options.Block <= 0 {
+		options.Block = 5 * time.Second
+	}
+	if options.ClaimAfter == 0 {
+		options.ClaimAfter = 30 * time.Second
+	}
+
+	return &RedisStreamQueueService{
+		client:  client,
+		stream:  stream,
+		options: options,
+	}
+}
+
+// CreateGroup creates the consumer group, reading the stream from its beginning.
+// It does nothing if the group already exists.
+func (r *RedisStreamQueueService) CreateGroup(ctx context.Context) error {
+	err := r.client.XGroupCreateMkStream(ctx, r.stream, r.options.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// Replay makes the group read the stream again from the entries following messageID ("0" for the beginning)
+func (r *RedisStreamQueueService) Replay(ctx context.Context, messageID string) error {
+	return r.client.XGroupSetID(ctx, r.stream, r.options.Group, messageID).Err()
+}
+
+func (r *RedisStreamQueueService) Push(ctx context.Context, value []byte) error {
+	return r.client.XAdd(ctx, r.addArgs(value, 0)).Err()
+}
+
+func (r *RedisStreamQueueService) addArgs(value []byte, deliveries int) *redis.XAddArgs {
+	values := map[string]interface{}{streamBodyField: value}
+	if deliveries > 0 {
+		values[streamDeliveriesField] = deliveries
+	}
+	args := &redis.XAddArgs{
+		Stream: r.stream,
+		Values: values,
+	}
+	if r.options.MaxLen > 0 {
+		args.MaxLen = r.options.MaxLen
+		args.Approx = true
+	} else if r.options.MaxAge > 0 {
+		args.MinID = r.minID()
+		args.Approx = true
+	}
+	return args
+}
+
+// Pop receives a message and acknowledges it straight away
+func (r *RedisStreamQueueService) Pop(ctx context.Context) ([]byte, error) {
+	message, err := r.Receive(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return message.Body, r.Ack(ctx, message)
+}
+
+// Receive returns a pending message idle for more than ClaimAfter if there is one,
+// and waits for a new message otherwise.
+func (r *RedisStreamQueueService) Receive(ctx context.Context) (Message, error) {
+	for {
+		if r.options.ClaimAfter > 0 {
+			message, found, err := r.claim(ctx)
+			if err != nil || found {
+				return message, err
+			}
+		}
+
+		streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    r.options.Group,
+			Consumer: r.options.Consumer,
+			Streams:  []string{r.stream, ">"},
+			Count:    1,
+			Block:    r.options.Block,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			if ctx.Err() != nil {
+				return Message{}, ctx.Err()
+			}
+			continue
+		}
+		if err != nil {
+			return Message{}, err
+		}
+
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				return toMessage(message, 1), nil
+			}
+		}
+	}
+}
+
+func (r *RedisStreamQueueService) claim(ctx context.Context) (Message, bool, error) {
+	messages, _, err := r.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   r.stream,
+		Group:    r.options.Group,
+		Consumer: r.options.Consumer,
+		MinIdle:  r.options.ClaimAfter,
+		Start:    "0-0",
+		Count:    1,
+	}).Result()
+	if err != nil || len(messages) == 0 {
+		return Message{}, false, err
+	}
+
+	pending, err := r.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: r.stream,
+		Group:  r.options.Group,
+		Start:  messages[0].ID,
+		End:    messages[0].ID,
+		Count:  1,
+	}).Result()
+	if err != nil {
+		return Message{}, false, err
+	}
+
+	deliveries := 0
+	if len(pending) > 0 {
+		deliveries = int(pending[0].RetryCount)
+	}
+	return toMessage(messages[0], deliveries), true, nil
+}
+
+// Ack removes the message from the group pending entries
+func (r *RedisStreamQueueService) Ack(ctx context.Context, message Message) error {
+	return r.client.XAck(ctx, r.stream, r.options.Group, message.ID).Err()
+}
+
+// Nack adds the message back at the end of the stream, keeping its delivery count, and acknowledges
+// the original entry. The message gets a new ID.
+func (r *RedisStreamQueueService) Nack(ctx context.Context, message Message) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, r.addArgs(message.Body, message.Deliveries))
+		pipe.XAck(ctx, r.stream, r.options.Group, message.ID)
+		return nil
+	})
+	return err
+}
+
+// Trim removes the entries exceeding MaxLen or older than MaxAge
+func (r *RedisStreamQueueService) Trim(ctx context.Context) error {
+	if r.options.MaxLen > 0 {
+		if err := r.client.XTrimMaxLen(ctx, r.stream, r.options.MaxLen).Err(); err != nil {
+			return err
+		}
+	}
+	if r.options.MaxAge > 0 {
+		if err := r.client.XTrimMinID(ctx, r.stream, r.minID()).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RedisStreamQueueService) minID() string {
+	return fmt.Sprintf("%d-0", time.Now().Add(-r.options.MaxAge).UnixMilli())
+}
+
+// toMessage converts a stream entry delivered deliveries times, adding the deliveries before it was nacked
+func toMessage(message redis.XMessage, deliveries int) Message {
+	body, _ := message.Values[streamBodyField].(string)
+	if previous, ok := message.Values[streamDeliveriesField].(string); ok {
+		if count, err := strconv.Atoi(previous); err == nil {
+			deliveries += count
+		}
+	}
+	return Message{
+		ID:         message.ID,
+		Body:       []byte(body),
+		Deliveries: deliveries,
+	}
+}
@@ -0,0 +1,157 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/owlint/goddd/services"
+	"github.com/owlint/goddd/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStreamQueue(t *testing.T, conn *redis.Client, options services.StreamQueueOptions) *services.RedisStreamQueueService {
+	options.Group = "consumers"
+	options.Block = 100 * time.Millisecond
+	queue := services.NewRedisStreamQueueService(conn, "test", options)
+	assert.NoError(t, queue.CreateGroup(context.Background()))
+	return queue
+}
+
+func TestStreamQueuePushPop(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := newTestStreamQueue(t, conn, services.StreamQueueOptions{})
+		hello := []byte("Hello")
+		world := []byte("world")
+
+		assert.NoError(t, queue.Push(context.Background(), hello))
+		assert.NoError(t, queue.Push(context.Background(), world))
+
+		res, err := queue.Pop(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, hello, res)
+		res, err = queue.Pop(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, world, res)
+	})
+}
+
+func TestStreamQueueCreateGroupTwice(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := newTestStreamQueue(t, conn, services.StreamQueueOptions{})
+
+		assert.NoError(t, queue.CreateGroup(context.Background()))
+	})
+}
+
+func TestStreamQueueLoadBalanced(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		consumer1 := newTestStreamQueue(t, conn, services.StreamQueueOptions{Consumer: "1"})
+		consumer2 := newTestStreamQueue(t, conn, services.StreamQueueOptions{Consumer: "2"})
+
+		assert.NoError(t, consumer1.Push(context.Background(), []byte("Hello")))
+		assert.NoError(t, consumer1.Push(context.Background(), []byte("world")))
+
+		message1, err := consumer1.Receive(context.Background())
+		assert.NoError(t, err)
+		message2, err := consumer2.Receive(context.Background())
+		assert.NoError(t, err)
+
+		assert.Equal(t, []byte("Hello"), message1.Body)
+		assert.Equal(t, []byte("world"), message2.Body)
+	})
+}
+
+func TestStreamQueuePendingRecovery(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		crashing := newTestStreamQueue(t, conn, services.StreamQueueOptions{Consumer: "crashing"})
+		recovering := newTestStreamQueue(t, conn, services.StreamQueueOptions{
+			Consumer:   "recovering",
+			ClaimAfter: 50 * time.Millisecond,
+		})
+
+		assert.NoError(t, crashing.Push(context.Background(), []byte("Hello")))
+		message, err := crashing.Receive(context.Background())
+		assert.NoError(t, err)
+
+		time.Sleep(100 * time.Millisecond)
+		claimed, err := recovering.Receive(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, message.ID, claimed.ID)
+		assert.Equal(t, []byte("Hello"), claimed.Body)
+		assert.Equal(t, 2, claimed.Deliveries)
+
+		assert.NoError(t, recovering.Ack(context.Background(), claimed))
+		pending, err := conn.XPending(context.Background(), "test", "consumers").Result()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, pending.Count)
+	})
+}
+
+func TestStreamQueueReceiveCancelled(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := newTestStreamQueue(t, conn, services.StreamQueueOptions{})
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		_, err := queue.Receive(ctx)
+
+		assert.Error(t, err)
+	})
+}
+
+func TestStreamQueueReplay(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := newTestStreamQueue(t, conn, services.StreamQueueOptions{})
+
+		assert.NoError(t, queue.Push(context.Background(), []byte("Hello")))
+		res, err := queue.Pop(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("Hello"), res)
+
+		assert.NoError(t, queue.Replay(context.Background(), "0"))
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		res, err = queue.Pop(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("Hello"), res)
+	})
+}
+
+func TestStreamQueueTrim(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := newTestStreamQueue(t, conn, services.StreamQueueOptions{MaxLen: 2})
+
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, conn.XAdd(context.Background(), &redis.XAddArgs{
+				Stream: "test",
+				Values: map[string]interface{}{"body": "Hello"},
+			}).Err())
+		}
+		assert.NoError(t, queue.Trim(context.Background()))
+
+		assert.EqualValues(t, 2, conn.XLen(context.Background(), "test").Val())
+	})
+}
+
+func TestStreamQueueNack(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := newTestStreamQueue(t, conn, services.StreamQueueOptions{})
+
+		assert.NoError(t, queue.Push(context.Background(), []byte("Hello")))
+		message, err := queue.Receive(context.Background())
+		assert.NoError(t, err)
+		assert.NoError(t, queue.Nack(context.Background(), message))
+
+		redelivered, err := queue.Receive(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("Hello"), redelivered.Body)
+		assert.Equal(t, 2, redelivered.Deliveries)
+
+		assert.NoError(t, queue.Ack(context.Background(), redelivered))
+		pending, err := conn.XPending(context.Background(), "test", "consumers").Result()
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0, pending.Count)
+	})
+}
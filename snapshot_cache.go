@@ -0,0 +1,301 @@
+package goddd
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/ristretto"
+	"github.com/go-redis/redis/v9"
+	"github.com/google/uuid"
+)
+
+// SnapshotCache keeps the last snapshots of objects to spare a snapshot query on Load.
+// A cache is best effort, the repository falls back to the snapshots collection on a miss.
+type SnapshotCache interface {
+	Get(ctx context.Context, key string) (Snapshot, bool)
+	// Set caches a newly saved snapshot unless a newer version of it is already cached
+	Set(ctx context.Context, key string, snapshot Snapshot)
+	// Fill caches a snapshot read from the snapshots collection, unless a newer version of it is already cached
+	Fill(ctx context.Context, key string, snapshot Snapshot)
+	Invalidate(ctx context.Context, key string)
+}
+
+// NoSnapshotCache disables snapshot caching
+type NoSnapshotCache struct{}
+
+func (NoSnapshotCache) Get(ctx context.Context, key string) (Snapshot, bool) {
+	return Snapshot{}, false
+}
+
+func (NoSnapshotCache) Set(ctx context.Context, key string, snapshot Snapshot) {}
+
+func (NoSnapshotCache) Fill(ctx context.Context, key string, snapshot Snapshot) {}
+
+func (NoSnapshotCache) Invalidate(ctx context.Context, key string) {}
+
+// RistrettoSnapshotCacheOptions configures the size of a RistrettoSnapshotCache
+type RistrettoSnapshotCacheOptions struct {
+	// MaxCost is the total cost of the cached snapshots, 1GB by default
+	MaxCost int64
+	// NumCounters is the number of keys tracked for admission, ten times the expected number of cached snapshots
+	NumCounters int64
+	// Cost returns the cost of a snapshot, its payload size by default
+	Cost func(Snapshot) int64
+}
+
+// RistrettoSnapshotCache is an in-process SnapshotCache
+type RistrettoSnapshotCache struct {
+	cache *ristretto.Cache
+	cost  func(Snapshot) int64
+}
+
+func NewRistrettoSnapshotCache(options RistrettoSnapshotCacheOptions) (*RistrettoSnapshotCache, error) {
+	if options.MaxCost == 0 {
+		options.MaxCost = 1 << 30
+	}
+	if options.NumCounters == 0 {
+		options.NumCounters = 1e5
+	}
+	if options.Cost == nil {
+		options.Cost = func(snapshot Snapshot) int64 {
+			return int64(len(snapshot.Payload))
+		}
+	}
+
+	cache, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: options.NumCounters,
+		MaxCost:     options.MaxCost,
+		BufferItems: 64,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RistrettoSnapshotCache{
+		cache: cache,
+		cost:  options.Cost,
+	}, nil
+}
+
+func (c *RistrettoSnapshotCache) Get(ctx context.Context, key string) (Snapshot, bool) {
+	snapshot, ok := c.cache.Get(key)
+	if !ok {
+		return Snapshot{}, false
+	}
+	return snapshot.(Snapshot), true
+}
+
+func (c *RistrettoSnapshotCache) Set(ctx context.Context, key string, snapshot Snapshot) {
+	if cached, ok := c.Get(ctx, key); ok && cached.Version > snapshot.Version {
+		return
+	}
+	c.cache.Set(key, snapshot, c.cost(snapshot))
+}
+
+func (c *RistrettoSnapshotCache) Fill(ctx context.Context, key string, snapshot Snapshot) {
+	c.Set(ctx, key, snapshot)
+}
+
+func (c *RistrettoSnapshotCache) Invalidate(ctx context.Context, key string) {
+	c.cache.Del(key)
+}
+
+// Wait blocks until the pending writes are applied, ristretto applying them asynchronously
+func (c *RistrettoSnapshotCache) Wait() {
+	c.cache.Wait()
+}
+
+// setIfNewer stores a snapshot in a hash unless the hash holds a newer version.
+// ARGV holds the version, the object ID, the payload and the TTL in milliseconds.
+var setIfNewer = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'version')
+if current and tonumber(current) > tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'version', ARGV[1], 'objectid', ARGV[2], 'payload', ARGV[3])
+if tonumber(ARGV[4]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[4])
+end
+return 1
+`)
+
+// RedisSnapshotCache is a SnapshotCache shared by every instance using the same Redis database.
+// Its errors are sent to errChan when it has room, nil discarding them, and handled as cache misses.
+type RedisSnapshotCache struct {
+	client  *redis.Client
+	prefix  string
+	ttl     time.Duration
+	errChan chan<- error
+}
+
+// NewRedisSnapshotCache creates a cache storing each snapshot in a hash named prefix followed by the cache key.
+// Snapshots expire ttl after being cached, never with a zero ttl.
+func NewRedisSnapshotCache(client *redis.Client, prefix string, ttl time.Duration, errChan chan<- error) *RedisSnapshotCache {
+	return &RedisSnapshotCache{
+		client:  client,
+		prefix:  prefix,
+		ttl:     ttl,
+		errChan: errChan,
+	}
+}
+
+func (c *RedisSnapshotCache) Get(ctx context.Context, key string) (Snapshot, bool) {
+	values, err := c.client.HGetAll(ctx, c.prefix+key).Result()
+	if err != nil {
+		c.report(ctx, err)
+		return Snapshot{}, false
+	}
+	if len(values) == 0 {
+		return Snapshot{}, false
+	}
+
+	version, err := strconv.Atoi(values["version"])
+	if err != nil {
+		c.report(ctx, err)
+		return Snapshot{}, false
+	}
+	return Snapshot{
+		ObjectID: values["objectid"],
+		Version:  version,
+		Payload:  []byte(values["payload"]),
+	}, true
+}
+
+func (c *RedisSnapshotCache) Set(ctx context.Context, key string, snapshot Snapshot) {
+	err := setIfNewer.Run(
+		ctx,
+		c.client,
+		[]string{c.prefix + key},
+		snapshot.Version, snapshot.ObjectID, snapshot.Payload, c.ttl.Milliseconds(),
+	).Err()
+	if err != nil {
+		c.report(ctx, err)
+	}
+}
+
+func (c *RedisSnapshotCache) Fill(ctx context.Context, key string, snapshot Snapshot) {
+	c.Set(ctx, key, snapshot)
+}
+
+func (c *RedisSnapshotCache) Invalidate(ctx context.Context, key string) {
+	if err := c.client.Del(ctx, c.prefix+key).Err(); err != nil {
+		c.report(ctx, err)
+	}
+}
+
+func (c *RedisSnapshotCache) report(ctx context.Context, err error) {
+	reportCacheError(c.errChan, err)
+}
+
+// SyncedSnapshotCache wraps an in-process cache and broadcasts its changes on a Redis channel,
+// so that the other instances drop the snapshots they hold when a newer one is saved or an object removed.
+// Run must be running for the changes of the other instances to be applied.
+type SyncedSnapshotCache struct {
+	local   SnapshotCache
+	client  *redis.Client
+	channel string
+	source  string
+	errChan chan<- error
+}
+
+type snapshotInvalidation struct {
+	Source string `json:"source"`
+	Key    string `json:"key"`
+	// Version is the version of the new snapshot, -1 when the object was removed
+	Version int `json:"version"`
+}
+
+func NewSyncedSnapshotCache(local SnapshotCache, client *redis.Client, channel string, errChan chan<- error) *SyncedSnapshotCache {
+	return &SyncedSnapshotCache{
+		local:   local,
+		client:  client,
+		channel: channel,
+		source:  uuid.NewString(),
+		errChan: errChan,
+	}
+}
+
+func (c *SyncedSnapshotCache) Get(ctx context.Context, key string) (Snapshot, bool) {
+	return c.local.Get(ctx, key)
+}
+
+// Set caches a newly saved snapshot and tells the other instances to drop their older version
+func (c *SyncedSnapshotCache) Set(ctx context.Context, key string, snapshot Snapshot) {
+	c.local.Set(ctx, key, snapshot)
+	c.broadcast(ctx, key, snapshot.Version)
+}
+
+// Fill only caches snapshot locally, the other instances read the same snapshot from the collection
+func (c *SyncedSnapshotCache) Fill(ctx context.Context, key string, snapshot Snapshot) {
+	c.local.Fill(ctx, key, snapshot)
+}
+
+func (c *SyncedSnapshotCache) Invalidate(ctx context.Context, key string) {
+	c.local.Invalidate(ctx, key)
+	c.broadcast(ctx, key, -1)
+}
+
+// Run applies the changes broadcast by the other instances until ctx is done, then returns ctx error
+func (c *SyncedSnapshotCache) Run(ctx context.Context) error {
+	subscription := c.client.Subscribe(ctx, c.channel)
+	defer subscription.Close()
+
+	messages := subscription.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case message, ok := <-messages:
+			if !ok {
+				return ctx.Err()
+			}
+			invalidation := snapshotInvalidation{}
+			if err := json.Unmarshal([]byte(message.Payload), &invalidation); err != nil {
+				c.report(ctx, err)
+				continue
+			}
+			c.apply(ctx, invalidation)
+		}
+	}
+}
+
+func (c *SyncedSnapshotCache) apply(ctx context.Context, invalidation snapshotInvalidation) {
+	if invalidation.Source == c.source {
+		return
+	}
+	cached, ok := c.local.Get(ctx, invalidation.Key)
+	if ok && (invalidation.Version < 0 || cached.Version < invalidation.Version) {
+		c.local.Invalidate(ctx, invalidation.Key)
+	}
+}
+
+func (c *SyncedSnapshotCache) broadcast(ctx context.Context, key string, version int) {
+	message, err := json.Marshal(snapshotInvalidation{
+		Source:  c.source,
+		Key:     key,
+		Version: version,
+	})
+	if err == nil {
+		err = c.client.Publish(ctx, c.channel, message).Err()
+	}
+	if err != nil {
+		c.report(ctx, err)
+	}
+}
+
+func (c *SyncedSnapshotCache) report(ctx context.Context, err error) {
+	reportCacheError(c.errChan, err)
+}
+
+// reportCacheError sends err to errChan without waiting, a cache failure being a miss.
+// The error is dropped when errChan is nil or full.
+func reportCacheError(errChan chan<- error, err error) {
+	if errChan == nil {
+		return
+	}
+	select {
+	case errChan <- err:
+	default:
+	}
+}
@@ -0,0 +1,144 @@
+package goddd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/google/uuid"
+	"github.com/owlint/goddd/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRistrettoSnapshotCacheKeepsNewest(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewRistrettoSnapshotCache(RistrettoSnapshotCacheOptions{})
+	assert.NoError(t, err)
+
+	cache.Set(ctx, "key", Snapshot{ObjectID: "id", Version: 600, Payload: []byte("new")})
+	cache.Wait()
+	cache.Set(ctx, "key", Snapshot{ObjectID: "id", Version: 500, Payload: []byte("old")})
+	cache.Wait()
+
+	snapshot, ok := cache.Get(ctx, "key")
+	assert.True(t, ok)
+	assert.Equal(t, 600, snapshot.Version)
+
+	cache.Invalidate(ctx, "key")
+	_, ok = cache.Get(ctx, "key")
+	assert.False(t, ok)
+}
+
+func TestRedisSnapshotCache(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		ctx := context.Background()
+		errChan := make(chan error, 10)
+		cache := NewRedisSnapshotCache(conn, "snapshots:", time.Minute, errChan)
+		other := NewRedisSnapshotCache(conn, "snapshots:", time.Minute, errChan)
+
+		_, ok := cache.Get(ctx, "key")
+		assert.False(t, ok)
+
+		cache.Set(ctx, "key", Snapshot{ObjectID: "id", Version: 600, Payload: []byte("new")})
+		other.Set(ctx, "key", Snapshot{ObjectID: "id", Version: 500, Payload: []byte("old")})
+
+		snapshot, ok := other.Get(ctx, "key")
+		assert.True(t, ok)
+		assert.Equal(t, Snapshot{ObjectID: "id", Version: 600, Payload: []byte("new")}, snapshot)
+
+		other.Invalidate(ctx, "key")
+		_, ok = cache.Get(ctx, "key")
+		assert.False(t, ok)
+		assert.Len(t, errChan, 0)
+	})
+}
+
+func TestSyncedSnapshotCache(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		errChan := make(chan error, 10)
+		channel := uuid.NewString()
+
+		newCache := func() *SyncedSnapshotCache {
+			local, err := NewRistrettoSnapshotCache(RistrettoSnapshotCacheOptions{})
+			assert.NoError(t, err)
+			cache := NewSyncedSnapshotCache(local, conn, channel, errChan)
+			go cache.Run(ctx)
+			return cache
+		}
+		first := newCache()
+		second := newCache()
+		time.Sleep(100 * time.Millisecond)
+
+		second.local.Set(ctx, "key", Snapshot{ObjectID: "id", Version: 500})
+		second.local.(*RistrettoSnapshotCache).Wait()
+
+		first.Set(ctx, "key", Snapshot{ObjectID: "id", Version: 600})
+		assert.Eventually(t, func() bool {
+			_, ok := second.Get(ctx, "key")
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+
+		first.local.(*RistrettoSnapshotCache).Wait()
+		second.Set(ctx, "key", Snapshot{ObjectID: "id", Version: 600})
+		second.local.(*RistrettoSnapshotCache).Wait()
+		second.Invalidate(ctx, "key")
+		assert.Eventually(t, func() bool {
+			_, ok := first.Get(ctx, "key")
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+		assert.Len(t, errChan, 0)
+	})
+}
+
+func TestRedisSnapshotCacheErrorsDoNotBlock(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:1", MaxRetries: -1})
+	defer client.Close()
+
+	for _, errChan := range []chan error{nil, make(chan error)} {
+		cache := NewRedisSnapshotCache(client, "snapshots:", time.Minute, errChan)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			cache.Set(context.Background(), "key", Snapshot{ObjectID: "id", Version: 600})
+			_, ok := cache.Get(context.Background(), "key")
+			assert.False(t, ok)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("cache blocked on reporting an error")
+		}
+	}
+}
+
+func TestSyncedSnapshotCacheFillIsLocal(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		errChan := make(chan error, 10)
+		channel := uuid.NewString()
+		subscription := conn.Subscribe(ctx, channel)
+		defer subscription.Close()
+		_, err := subscription.Receive(ctx)
+		assert.NoError(t, err)
+
+		local, err := NewRistrettoSnapshotCache(RistrettoSnapshotCacheOptions{})
+		assert.NoError(t, err)
+		cache := NewSyncedSnapshotCache(local, conn, channel, errChan)
+
+		cache.Fill(ctx, "key", Snapshot{ObjectID: "id", Version: 600})
+		local.Wait()
+		_, ok := cache.Get(ctx, "key")
+		assert.True(t, ok)
+
+		cache.Set(ctx, "key", Snapshot{ObjectID: "id", Version: 700})
+		message, err := subscription.ReceiveMessage(ctx)
+		assert.NoError(t, err)
+		assert.Contains(t, message.Payload, `"version":700`)
+		assert.Len(t, errChan, 0)
+	})
+}
@@ -0,0 +1,84 @@
+package goddd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+var ErrReceiverQuarantined = errors.New("receiver is quarantined after repeated panics")
+
+// PanicError is a panic recovered while a receiver was handling an event
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("receiver panicked : %v", e.Value)
+}
+
+// supervisor tracks the panics of a single receiver.
+// It is only used from the receiver worker goroutine.
+type supervisor struct {
+	panics           int
+	quarantinedUntil time.Time
+	quarantined      bool
+}
+
+func (s *supervisor) isQuarantined(now time.Time) bool {
+	if !s.quarantined {
+		return false
+	}
+	// A zero time means the receiver stays quarantined
+	return s.quarantinedUntil.IsZero() || now.Before(s.quarantinedUntil)
+}
+
+// record updates the panic count with a delivery result, quarantining the
+// receiver once it panicked threshold times in a row. A threshold of 0 disables quarantine.
+func (s *supervisor) record(err error, threshold int, duration time.Duration) {
+	var panicErr *PanicError
+	if !errors.As(err, &panicErr) {
+		s.panics = 0
+		s.quarantined = false
+		return
+	}
+
+	s.panics++
+	if threshold > 0 && s.panics >= threshold {
+		s.quarantined = true
+		s.quarantinedUntil = time.Time{}
+		if duration > 0 {
+			s.quarantinedUntil = time.Now().Add(duration)
+		}
+	}
+}
+
+func safeHandle(ctx context.Context, handler EventHandler, event Event) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{
+				Value: value,
+				Stack: debug.Stack(),
+			}
+		}
+	}()
+
+	return handler.HandleEvent(ctx, event)
+}
+
+// safeHandleBatch is safeHandle for a batch of events
+func safeHandleBatch(ctx context.Context, handler BatchEventHandler, events []Event) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{
+				Value: value,
+				Stack: debug.Stack(),
+			}
+		}
+	}()
+
+	return handler.HandleEvents(ctx, events)
+}
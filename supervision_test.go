@@ -0,0 +1,143 @@
+package goddd
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type panickingHandler struct {
+	calls int
+}
+
+func (h *panickingHandler) HandleEvent(ctx context.Context, event Event) error {
+	h.calls++
+	panic("projection exploded")
+}
+
+func TestPanicRecovered(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.Wait = true
+	panics := make([]*PanicError, 0)
+	errs := make([]error, 0)
+	publisher.OnPanic = func(event Event, err *PanicError) {
+		panics = append(panics, err)
+	}
+	publisher.OnError = func(event Event, err error) {
+		errs = append(errs, err)
+	}
+	receiver := testReceiver{
+		events: make([]Event, 0),
+	}
+
+	publisher.RegisterHandler(&panickingHandler{})
+	publisher.Register(&receiver)
+	publisher.Publish([]Event{NewEvent("TestObject", "name", 1, []byte{1, 2})})
+
+	assert.Len(t, receiver.received(), 1)
+	assert.Len(t, panics, 1)
+	assert.Equal(t, "projection exploded", panics[0].Value)
+	assert.Contains(t, string(panics[0].Stack), "panickingHandler")
+	assert.Len(t, errs, 1)
+	assert.ErrorAs(t, errs[0], &panics[0])
+}
+
+func TestPanickingReceiverQuarantined(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.Wait = true
+	publisher.QuarantineAfter = 2
+	publisher.DeadLetters = NewInMemoryDeadLetterStore()
+	handler := panickingHandler{}
+	receiver := testReceiver{
+		events: make([]Event, 0),
+	}
+
+	publisher.RegisterHandler(&handler)
+	publisher.Register(&receiver)
+	for i := 0; i < 5; i++ {
+		publisher.Publish([]Event{NewEvent("TestObject", "name", i, []byte{1, 2})})
+	}
+
+	assert.Equal(t, 2, handler.calls)
+	assert.Len(t, receiver.received(), 5)
+	letters, err := publisher.DeadLetters.List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, letters, 5)
+	assert.Equal(t, ErrReceiverQuarantined.Error(), letters[4].Error)
+}
+
+func TestQuarantineExpires(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.Wait = true
+	publisher.QuarantineAfter = 1
+	publisher.QuarantineDuration = 50 * time.Millisecond
+	handler := panickingHandler{}
+
+	publisher.RegisterHandler(&handler)
+	publisher.Publish([]Event{NewEvent("TestObject", "name", 1, []byte{1, 2})})
+	publisher.Publish([]Event{NewEvent("TestObject", "name", 2, []byte{1, 2})})
+	assert.Equal(t, 1, handler.calls)
+
+	time.Sleep(60 * time.Millisecond)
+	publisher.Publish([]Event{NewEvent("TestObject", "name", 3, []byte{1, 2})})
+	publisher.Publish([]Event{NewEvent("TestObject", "name", 4, []byte{1, 2})})
+	assert.Equal(t, 2, handler.calls)
+}
+
+type panickingBatchHandler struct {
+	mutex sync.Mutex
+	gate  chan struct{}
+	calls int
+}
+
+func (h *panickingBatchHandler) HandleEvent(ctx context.Context, event Event) error {
+	<-h.gate
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.calls++
+	return nil
+}
+
+func (h *panickingBatchHandler) HandleEvents(ctx context.Context, events []Event) error {
+	panic("batch exploded")
+}
+
+func TestPanickingBatchQuarantined(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.QuarantineAfter = 1
+	publisher.DeadLetters = NewInMemoryDeadLetterStore()
+	var mutex sync.Mutex
+	panics := make([]Event, 0)
+	publisher.OnPanic = func(event Event, err *PanicError) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		panics = append(panics, event)
+	}
+	handler := &panickingBatchHandler{gate: make(chan struct{})}
+	publisher.RegisterHandler(handler)
+
+	publisher.OnEvent(NewEvent("TestObject", "name", 1, []byte{1, 2}))
+	// HACK: wait for the first event to be handled alone
+	time.Sleep(50 * time.Millisecond)
+	batch := make([]Event, 0)
+	for i := 2; i <= 4; i++ {
+		event := NewEvent("TestObject", "name", i, []byte{1, 2})
+		batch = append(batch, event)
+		publisher.OnEvent(event)
+	}
+	close(handler.gate)
+	assert.NoError(t, publisher.Close(context.Background()))
+
+	assert.Equal(t, 1, handler.calls)
+	assert.Len(t, panics, 1)
+	assertEventsEqual(t, batch[0], panics[0])
+	letters, err := publisher.DeadLetters.List(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, letters, 3)
+	for _, letter := range letters {
+		assert.Equal(t, ErrReceiverQuarantined.Error(), letter.Error)
+	}
+}
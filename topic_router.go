@@ -0,0 +1,319 @@
+package goddd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/owlint/goddd/services"
+)
+
+// QueueFactory opens the queue with the given name
+type QueueFactory func(name string) services.QueueService
+
+// TopicRouter is an EventReceiver pushing every event to each queue having a matching route.
+// Routes declared with Route are local to the router. Consumer subscriptions are saved in Store
+// when set, so that the routers of every publisher deliver to them once refreshed.
+type TopicRouter struct {
+	factory    QueueFactory
+	errChan    chan<- error
+	mutex      sync.RWMutex
+	routes     []route
+	subscribed map[string]Topic
+	queues     map[string]services.QueueService
+	publishers map[string]*RemoteEventPublisher
+
+	// Store shares the consumer subscriptions between routers
+	Store RouteStore
+	// NewPublisher creates the publisher of a routed queue, a RemoteEventPublisher with default options if nil.
+	// It sets how events are encoded.
+	NewPublisher func(queue services.QueueService, errChan chan<- error) *RemoteEventPublisher
+}
+
+type route struct {
+	queueName string
+	filters   []EventFilter
+}
+
+// Topic declares the events a consumer is interested in as path.Match patterns.
+// An event matches when its name matches one of EventNames and its object type one of ObjectTypes,
+// an empty list matching every event.
+type Topic struct {
+	EventNames  []string `json:"eventNames,omitempty"`
+	ObjectTypes []string `json:"objectTypes,omitempty"`
+}
+
+func (t Topic) filters() []EventFilter {
+	filters := make([]EventFilter, 0, 2)
+	if len(t.EventNames) > 0 {
+		filters = append(filters, matchAny(t.EventNames, MatchEventName))
+	}
+	if len(t.ObjectTypes) > 0 {
+		filters = append(filters, matchAny(t.ObjectTypes, MatchObjectType))
+	}
+	return filters
+}
+
+func matchAny(patterns []string, match func(pattern string) EventFilter) EventFilter {
+	filters := make([]EventFilter, len(patterns))
+	for i, pattern := range patterns {
+		filters[i] = match(pattern)
+	}
+	return func(event Event) bool {
+		for _, filter := range filters {
+			if filter(event) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RouteStore keeps the consumer subscriptions shared by the routers
+type RouteStore interface {
+	SaveTopic(ctx context.Context, consumer string, topic Topic) error
+	Topics(ctx context.Context) (map[string]Topic, error)
+}
+
+// InMemoryRouteStore is a RouteStore shared by the routers of a single process
+type InMemoryRouteStore struct {
+	mutex  sync.RWMutex
+	topics map[string]Topic
+}
+
+func NewInMemoryRouteStore() *InMemoryRouteStore {
+	return &InMemoryRouteStore{
+		topics: make(map[string]Topic),
+	}
+}
+
+func (s *InMemoryRouteStore) SaveTopic(ctx context.Context, consumer string, topic Topic) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.topics[consumer] = topic
+	return nil
+}
+
+func (s *InMemoryRouteStore) Topics(ctx context.Context) (map[string]Topic, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	topics := make(map[string]Topic, len(s.topics))
+	for consumer, topic := range s.topics {
+		topics[consumer] = topic
+	}
+	return topics, nil
+}
+
+// RedisRouteStore is a RouteStore keeping the topics as JSON in a Redis hash, by consumer
+type RedisRouteStore struct {
+	client *redis.Client
+	key    string
+}
+
+func NewRedisRouteStore(client *redis.Client, key string) *RedisRouteStore {
+	return &RedisRouteStore{
+		client: client,
+		key:    key,
+	}
+}
+
+func (s *RedisRouteStore) SaveTopic(ctx context.Context, consumer string, topic Topic) error {
+	value, err := json.Marshal(topic)
+	if err != nil {
+		return err
+	}
+	return s.client.HSet(ctx, s.key, consumer, value).Err()
+}
+
+func (s *RedisRouteStore) Topics(ctx context.Context) (map[string]Topic, error) {
+	values, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	topics := make(map[string]Topic, len(values))
+	for consumer, value := range values {
+		var topic Topic
+		if err := json.Unmarshal([]byte(value), &topic); err != nil {
+			return nil, fmt.Errorf("invalid topic of consumer %s : %w", consumer, err)
+		}
+		topics[consumer] = topic
+	}
+	return topics, nil
+}
+
+func NewTopicRouter(factory QueueFactory, errChan chan<- error) *TopicRouter {
+	return &TopicRouter{
+		factory:    factory,
+		errChan:    errChan,
+		routes:     make([]route, 0),
+		subscribed: make(map[string]Topic),
+		queues:     make(map[string]services.QueueService),
+		publishers: make(map[string]*RemoteEventPublisher),
+	}
+}
+
+// Route sends the events matching all the filters to the named queue.
+// Several routes may target the same queue, an event is pushed once per queue.
+func (r *TopicRouter) Route(queueName string, filters ...EventFilter) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.routes = append(r.routes, route{
+		queueName: queueName,
+		filters:   filters,
+	})
+	r.queue(queueName)
+}
+
+// Subscribe declares the events a consumer is interested in, saving them in Store when set,
+// and returns its own queue to listen to. A consumer subscribing again replaces its topic.
+func (r *TopicRouter) Subscribe(ctx context.Context, consumer string, topic Topic) (services.QueueService, error) {
+	if r.Store != nil {
+		if err := r.Store.SaveTopic(ctx, consumer, topic); err != nil {
+			return nil, fmt.Errorf("could not save the topic of %s : %w", consumer, err)
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.subscribed[consumer] = topic
+	return r.queue(consumer), nil
+}
+
+// Refresh replaces the subscriptions by the ones saved in Store
+func (r *TopicRouter) Refresh(ctx context.Context) error {
+	if r.Store == nil {
+		return nil
+	}
+
+	topics, err := r.Store.Topics(ctx)
+	if err != nil {
+		return fmt.Errorf("could not load the routed topics : %w", err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.subscribed = topics
+	for consumer := range topics {
+		r.queue(consumer)
+	}
+	return nil
+}
+
+// Run refreshes the subscriptions now and then every interval until ctx is done
+func (r *TopicRouter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Refresh(ctx); err != nil && ctx.Err() == nil {
+			r.errChan <- err
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// QueueNames returns the names of the queues matching event
+func (r *TopicRouter) QueueNames(event Event) []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, route := range r.routes {
+		if _, ok := seen[route.queueName]; ok || !matchesAll(route.filters, event) {
+			continue
+		}
+		seen[route.queueName] = struct{}{}
+		names = append(names, route.queueName)
+	}
+
+	subscribed := make([]string, 0)
+	for consumer, topic := range r.subscribed {
+		if _, ok := seen[consumer]; ok || !matchesAll(topic.filters(), event) {
+			continue
+		}
+		subscribed = append(subscribed, consumer)
+	}
+	sort.Strings(subscribed)
+	return append(names, subscribed...)
+}
+
+func (r *TopicRouter) OnEvent(event Event) {
+	r.OnEvents([]Event{event})
+}
+
+// OnEvents pushes the events of each queue at once, encoded by its RemoteEventPublisher
+func (r *TopicRouter) OnEvents(events []Event) {
+	names := make([]string, 0)
+	batches := make(map[string][]Event)
+	for _, event := range events {
+		for _, name := range r.QueueNames(event) {
+			if _, ok := batches[name]; !ok {
+				names = append(names, name)
+			}
+			batches[name] = append(batches[name], event)
+		}
+	}
+
+	for _, name := range names {
+		r.publisher(name).OnEvents(batches[name])
+	}
+}
+
+// queue returns the named queue, opening it if needed. The caller must hold the write lock.
+func (r *TopicRouter) queue(name string) services.QueueService {
+	queue, ok := r.queues[name]
+	if !ok {
+		queue = r.factory(name)
+		r.queues[name] = queue
+	}
+	return queue
+}
+
+// publisher returns the publisher of the named queue, creating it if needed
+func (r *TopicRouter) publisher(name string) *RemoteEventPublisher {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	publisher, ok := r.publishers[name]
+	if !ok {
+		newPublisher := r.NewPublisher
+		if newPublisher == nil {
+			newPublisher = NewRemoteEventPublisher
+		}
+		publisher = newPublisher(r.queue(name), r.errChan)
+		r.publishers[name] = publisher
+	}
+	return publisher
+}
+
+// MatchEventName keeps the events whose name matches the path.Match pattern, e.g. "Grade*"
+func MatchEventName(pattern string) EventFilter {
+	return func(event Event) bool {
+		matched, err := path.Match(pattern, event.Name())
+		return err == nil && matched
+	}
+}
+
+// MatchObjectType keeps the events of objects whose type matches the path.Match pattern.
+// The type of an object is the one given to NewIdentity.
+func MatchObjectType(pattern string) EventFilter {
+	return func(event Event) bool {
+		matched, err := path.Match(pattern, ObjectType(event.ObjectId()))
+		return err == nil && matched
+	}
+}
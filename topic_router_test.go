@@ -0,0 +1,110 @@
+package goddd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/owlint/goddd/services"
+	"github.com/owlint/goddd/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchEventName(t *testing.T) {
+	filter := MatchEventName("Grade*")
+
+	assert.True(t, filter(NewEvent("TestObject", "GradeSet", 1, nil)))
+	assert.True(t, filter(NewEvent("TestObject", "GradeRemoved", 1, nil)))
+	assert.False(t, filter(NewEvent("TestObject", "NameSet", 1, nil)))
+	assert.False(t, MatchEventName("[")(NewEvent("TestObject", "GradeSet", 1, nil)))
+}
+
+func TestMatchObjectType(t *testing.T) {
+	filter := MatchObjectType("Student*")
+
+	assert.True(t, filter(NewEvent(NewIdentity("Student"), "GradeSet", 1, nil)))
+	assert.True(t, filter(NewEvent(NewIdentity("StudentGroup"), "GradeSet", 1, nil)))
+	assert.False(t, filter(NewEvent(NewIdentity("Teacher"), "GradeSet", 1, nil)))
+}
+
+func TestTopicRouterQueueNames(t *testing.T) {
+	router := NewTopicRouter(func(name string) services.QueueService { return nil }, nil)
+	router.Route("grades", MatchEventName("Grade*"))
+	router.Route("students", MatchObjectType("Student"))
+	router.Route("grades", ForEventNames("GradeRemoved"))
+
+	assert.Equal(t, []string{"grades", "students"}, router.QueueNames(NewEvent(NewIdentity("Student"), "GradeSet", 1, nil)))
+	assert.Equal(t, []string{"grades"}, router.QueueNames(NewEvent(NewIdentity("Teacher"), "GradeRemoved", 1, nil)))
+	assert.Equal(t, []string{"students"}, router.QueueNames(NewEvent(NewIdentity("Student"), "NameSet", 1, nil)))
+	assert.Empty(t, router.QueueNames(NewEvent(NewIdentity("Teacher"), "NameSet", 1, nil)))
+}
+
+func TestTopicRouterFanOut(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		errChan := make(chan error, 1)
+		router := NewTopicRouter(func(name string) services.QueueService {
+			return services.NewRedisQueueService(conn, name)
+		}, errChan)
+
+		billing, err := router.Subscribe(context.Background(), "billing", Topic{EventNames: []string{"Grade*"}})
+		assert.NoError(t, err)
+		audit, err := router.Subscribe(context.Background(), "audit", Topic{})
+		assert.NoError(t, err)
+
+		event := NewEvent(NewIdentity("Student"), "GradeSet", 1, []byte{1, 2})
+		router.OnEvents([]Event{event, NewEvent(NewIdentity("Student"), "NameSet", 2, []byte{1, 2})})
+
+		assert.Len(t, errChan, 0)
+		assert.EqualValues(t, 1, conn.LLen(context.Background(), "billing").Val())
+		assert.EqualValues(t, 2, conn.LLen(context.Background(), "audit").Val())
+
+		serialized, err := billing.Pop(context.Background())
+		assert.NoError(t, err)
+		received, envelope, err := DecodeMessage(serialized)
+		assert.NoError(t, err)
+		assertEventsEqual(t, event, received)
+		assert.Equal(t, ContentTypeProtobuf, envelope.ContentType)
+
+		serialized, err = audit.Pop(context.Background())
+		assert.NoError(t, err)
+		received, _, err = DecodeMessage(serialized)
+		assert.NoError(t, err)
+		assertEventsEqual(t, event, received)
+	})
+}
+
+func TestTopicRouterSharedRoutes(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		errChan := make(chan error, 1)
+		factory := func(name string) services.QueueService {
+			return services.NewRedisQueueService(conn, name)
+		}
+		consumerRouter := NewTopicRouter(factory, errChan)
+		consumerRouter.Store = NewRedisRouteStore(conn, "routes")
+		publisherRouter := NewTopicRouter(factory, errChan)
+		publisherRouter.Store = NewRedisRouteStore(conn, "routes")
+		publisherRouter.NewPublisher = func(queue services.QueueService, errChan chan<- error) *RemoteEventPublisher {
+			publisher := NewRemoteEventPublisher(queue, errChan)
+			publisher.Producer = "grades"
+			return publisher
+		}
+
+		_, err := consumerRouter.Subscribe(context.Background(), "billing", Topic{ObjectTypes: []string{"Student"}})
+		assert.NoError(t, err)
+		event := NewEvent(NewIdentity("Student"), "GradeSet", 1, []byte{1, 2})
+		assert.Empty(t, publisherRouter.QueueNames(event))
+
+		assert.NoError(t, publisherRouter.Refresh(context.Background()))
+		assert.Equal(t, []string{"billing"}, publisherRouter.QueueNames(event))
+		assert.Empty(t, publisherRouter.QueueNames(NewEvent(NewIdentity("Teacher"), "GradeSet", 1, nil)))
+
+		publisherRouter.OnEvent(event)
+		assert.Len(t, errChan, 0)
+		serialized, err := conn.RPop(context.Background(), "billing").Bytes()
+		assert.NoError(t, err)
+		received, envelope, err := DecodeMessage(serialized)
+		assert.NoError(t, err)
+		assertEventsEqual(t, event, received)
+		assert.Equal(t, "grades", envelope.Headers[HeaderProducer])
+	})
+}
@@ -0,0 +1,58 @@
+package goddd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tinylib/msgp/msgp"
+)
+
+// HandlerRegistry is implemented by the components EventHandlers can subscribe to,
+// such as EventPublisher and RemoteEventListener
+type HandlerRegistry interface {
+	RegisterHandler(handler EventHandler, filters ...EventFilter) *Subscription
+}
+
+// DecodeError is returned when an event payload cannot be decoded by a typed handler
+type DecodeError struct {
+	Event Event
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("could not decode payload of event %s (%s) : %s", e.Event.Id(), e.Event.Name(), e.Err.Error())
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent tells retry policies that decoding the same payload again will fail again
+func (e *DecodeError) Permanent() bool {
+	return true
+}
+
+// Handle subscribes handler to the events named eventName, decoding their payload into T:
+//
+//	goddd.Handle[GradeSet](publisher, "GradeSet", func(ctx context.Context, meta goddd.Event, payload GradeSet) error {
+//		...
+//	})
+//
+// Payloads that cannot be decoded are reported as a *DecodeError through the registry error path.
+func Handle[T any, PT interface {
+	*T
+	msgp.Unmarshaler
+}](registry HandlerRegistry, eventName string, handler func(ctx context.Context, meta Event, payload T) error, filters ...EventFilter) *Subscription {
+	eventFilters := make([]EventFilter, 0, len(filters)+1)
+	eventFilters = append(eventFilters, ForEventNames(eventName))
+	eventFilters = append(eventFilters, filters...)
+
+	// The handler is named after the given function so that dead letters tell typed handlers apart
+	return registry.RegisterHandler(Named(funcName(handler), ReceiverFunc(func(ctx context.Context, event Event) error {
+		var payload T
+		if _, err := PT(&payload).UnmarshalMsg(event.Payload()); err != nil {
+			return &DecodeError{Event: event, Err: err}
+		}
+		return handler(ctx, event, payload)
+	})), eventFilters...)
+}
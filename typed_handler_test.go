@@ -0,0 +1,93 @@
+package goddd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/owlint/goddd/services"
+	"github.com/owlint/goddd/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func gradeSetEvent(t *testing.T, grade string) Event {
+	payload, err := GradeSet{Grade: grade}.MarshalMsg(nil)
+	assert.NoError(t, err)
+	return NewEvent(NewIdentity("Student"), "GradeSet", 1, payload)
+}
+
+func TestHandleTypedPayload(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.Wait = true
+	grades := make([]string, 0)
+
+	Handle[GradeSet](&publisher, "GradeSet", func(ctx context.Context, meta Event, payload GradeSet) error {
+		grades = append(grades, payload.Grade)
+		return nil
+	})
+	publisher.Publish([]Event{
+		gradeSetEvent(t, "a"),
+		NewEvent(NewIdentity("Student"), "NameSet", 2, []byte{1, 2}),
+		gradeSetEvent(t, "b"),
+	})
+
+	assert.Equal(t, []string{"a", "b"}, grades)
+}
+
+func TestHandleDecodeError(t *testing.T) {
+	publisher := NewEventPublisher()
+	publisher.Wait = true
+	publisher.Retry = RetryPolicy{MaxRetries: 3}
+	errs := make([]error, 0)
+	publisher.OnError = func(event Event, err error) {
+		errs = append(errs, err)
+	}
+	calls := 0
+
+	Handle[GradeSet](&publisher, "GradeSet", func(ctx context.Context, meta Event, payload GradeSet) error {
+		calls++
+		return nil
+	})
+	event := NewEvent(NewIdentity("Student"), "GradeSet", 1, []byte{1, 2})
+	publisher.Publish([]Event{event})
+
+	assert.Equal(t, 0, calls)
+	assert.Len(t, errs, 1)
+	var decodeErr *DecodeError
+	assert.ErrorAs(t, errs[0], &decodeErr)
+	assert.Equal(t, event.Id(), decodeErr.Event.Id())
+}
+
+func TestHandleRemoteListener(t *testing.T) {
+	testutils.WithTestRedis(func(conn *redis.Client) {
+		queue := services.NewRedisQueueService(conn, "test")
+		errChan := make(chan error, 2)
+		grades := make(chan string, 2)
+
+		remotePublisher := NewRemoteEventPublisher(queue, errChan)
+		remoteListener := NewRemoteEventListener(queue, nil, errChan)
+		Handle[GradeSet](&remoteListener, "GradeSet", func(ctx context.Context, meta Event, payload GradeSet) error {
+			if payload.Grade == "f" {
+				return errors.New("failing grade")
+			}
+			grades <- payload.Grade
+			return nil
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go remoteListener.Listen(ctx)
+		remotePublisher.OnEvent(gradeSetEvent(t, "a"))
+		remotePublisher.OnEvent(gradeSetEvent(t, "f"))
+
+		// HACK: wait for events to be processed by listener
+		time.Sleep(time.Second)
+
+		assert.Len(t, grades, 1)
+		assert.Equal(t, "a", <-grades)
+		assert.Len(t, errChan, 1)
+		assert.EqualError(t, <-errChan, "failing grade")
+	})
+}